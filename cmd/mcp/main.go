@@ -0,0 +1,36 @@
+// Comando mcp expone las acciones como servidor del Model Context Protocol
+// por stdio, para que agentes externos (IDEs, otros asistentes) las usen
+// como herramientas. stdout queda reservado para el protocolo: los logs van
+// a stderr.
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/ivanneira/Lapislazuli/config"
+	"github.com/ivanneira/Lapislazuli/internal/api"
+	"github.com/ivanneira/Lapislazuli/internal/coordinator"
+	"github.com/ivanneira/Lapislazuli/internal/logger"
+	"github.com/ivanneira/Lapislazuli/internal/mcpproto"
+)
+
+func main() {
+	logger.SetOutput(os.Stderr)
+	config.LoadConfig()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	info := mcpproto.Implementation{Name: "lapislazuli", Version: api.Version}
+	closeServers := coordinator.LoadActions(ctx, info)
+	defer closeServers()
+
+	server := mcpproto.NewServer(info.Name, info.Version, coordinator.NewTools())
+	if err := mcpproto.ServeStdio(ctx, server, os.Stdin, os.Stdout); err != nil && ctx.Err() == nil {
+		log.Fatalf("Error en el servidor MCP: %v", err)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/ivanneira/Lapislazuli/config"
+	"github.com/ivanneira/Lapislazuli/internal/api"
+	"github.com/ivanneira/Lapislazuli/internal/coordinator"
+	"github.com/ivanneira/Lapislazuli/internal/mcp"
+	"github.com/ivanneira/Lapislazuli/internal/processor"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestPayload representa el JSON de entrada.
+type RequestPayload struct {
+	Text      string `json:"text"`
+	SessionID string `json:"session_id"`
+	Locale    string `json:"locale"`
+}
+
+// statusClientClosedRequest es el estado que se registra cuando el cliente
+// cortó la conexión antes de la respuesta (el 499 de nginx).
+const statusClientClosedRequest = 499
+
+// Eventos SSE propios del servidor, además de los del coordinador.
+const (
+	eventDone  = "done"
+	eventError = "error"
+)
+
+// indexHandler procesa el prompt y responde con un api.IndexResponse.
+func indexHandler(client *mcp.AIClient) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID, payload, ok := bindRequest(c)
+		if !ok {
+			return
+		}
+		status, resp := process(c.Request.Context(), client, requestID, payload)
+		if status == statusClientClosedRequest {
+			// El cliente ya no está para leer la respuesta
+			c.Status(status)
+			return
+		}
+		c.JSON(status, resp)
+	}
+}
+
+// streamHandler procesa el prompt emitiendo eventos SSE: classified,
+// action_started, action_output, un token por fragmento de la respuesta y,
+// al final, done (o error) con el api.IndexResponse completo.
+func streamHandler(client *mcp.AIClient) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID, payload, ok := bindRequest(c)
+		if !ok {
+			return
+		}
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+
+		ctx := coordinator.WithEvents(c.Request.Context(), func(name string, data interface{}) {
+			c.SSEvent(name, data)
+			c.Writer.Flush()
+		})
+		status, resp := process(ctx, client, requestID, payload)
+
+		switch status {
+		case statusClientClosedRequest:
+			return
+		case http.StatusOK:
+			c.SSEvent(eventDone, resp)
+		default:
+			c.SSEvent(eventError, resp)
+		}
+		c.Writer.Flush()
+	}
+}
+
+// bindRequest asigna el ID de petición y decodifica el payload. Si falla,
+// ya respondió con 400.
+func bindRequest(c *gin.Context) (string, RequestPayload, bool) {
+	requestID := c.GetHeader(api.RequestIDHeader)
+	if requestID == "" {
+		requestID = api.NewRequestID()
+	}
+	c.Header(api.RequestIDHeader, requestID)
+
+	var payload RequestPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, api.NewError(requestID, "invalid_request", err.Error()))
+		return requestID, payload, false
+	}
+	return requestID, payload, true
+}
+
+// process procesa el prompt en su sesión y arma la respuesta con su estado HTTP.
+// Todo el procesamiento se cancela si ctx se cancela o vence REQUEST_TIMEOUT.
+func process(ctx context.Context, client *mcp.AIClient, requestID string, payload RequestPayload) (int, api.IndexResponse) {
+	start := time.Now()
+	if config.Config.RequestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.Config.RequestTimeout)
+		defer cancel()
+	}
+
+	// Sin session_id se abre una sesión nueva que el cliente puede continuar
+	sessionID := payload.SessionID
+	if sessionID == "" {
+		sessionID = api.NewSessionID()
+	}
+	properties := map[string]interface{}{}
+	if payload.Locale != "" {
+		properties[coordinator.PropertyLocale] = payload.Locale
+	}
+
+	// Procesa el prompt con el historial de la sesión
+	aiResp := client.Process(ctx, mcp.AIRequest{
+		Input:      payload.Text,
+		SessionID:  sessionID,
+		Properties: properties,
+	})
+	err := aiResp.Error
+
+	resp := api.NewResponse(requestID)
+	resp.SessionID = sessionID
+	var result coordinator.Result
+	if aiResp.Output != "" {
+		if jsonErr := json.Unmarshal([]byte(aiResp.Output), &result); jsonErr != nil && err == nil {
+			err = jsonErr
+		}
+		resp.Action = result.Action
+		resp.Params = result.Params
+		resp.Confidence = result.Confidence
+		for _, c := range result.Candidates {
+			resp.Candidates = append(resp.Candidates, api.Candidate{Action: c.Action, Confidence: c.Confidence})
+		}
+		resp.Clarification = result.Clarification
+		resp.Message = result.Response.Message
+		resp.Status = result.Response.Status
+		resp.Data = result.Response.Data
+		resp.FollowUp = result.Response.FollowUp
+		resp.Plan = planSteps(result.Plan)
+		resp.ToolCalls = planSteps(result.Calls)
+		resp.Reply = result.Reply
+		resp.Timing.ClassificationMs = api.Millis(result.ClassificationTime)
+		resp.Timing.ExecutionMs = api.Millis(result.ExecutionTime)
+		resp.Timing.ReplyMs = api.Millis(result.ReplyTime)
+		resp.Usage = api.Usage{
+			PromptTokens:     result.Usage.PromptTokens,
+			CompletionTokens: result.Usage.CompletionTokens,
+			TotalTokens:      result.Usage.TotalTokens,
+		}
+	}
+	resp.Timing.TotalMs = api.Millis(time.Since(start))
+
+	var actionErr *coordinator.ActionError
+	var timeoutErr *coordinator.TimeoutError
+	var paramsErr *coordinator.InvalidParamsError
+	switch {
+	case errors.As(err, &timeoutErr):
+		resp.Error = &api.ErrorBody{Code: "action_timeout", Message: timeoutErr.Error()}
+		return http.StatusGatewayTimeout, resp
+	case errors.Is(err, context.DeadlineExceeded):
+		resp.Error = &api.ErrorBody{Code: "request_timeout", Message: err.Error()}
+		return http.StatusGatewayTimeout, resp
+	case errors.Is(err, context.Canceled):
+		resp.Error = &api.ErrorBody{Code: "request_canceled", Message: err.Error()}
+		return statusClientClosedRequest, resp
+	case errors.Is(err, processor.ErrMaxIterations):
+		resp.Error = &api.ErrorBody{Code: "agent_max_iterations", Message: err.Error()}
+		return http.StatusInternalServerError, resp
+	case errors.As(err, &paramsErr):
+		resp.Error = &api.ErrorBody{Code: "invalid_params", Message: paramsErr.Error()}
+		return http.StatusUnprocessableEntity, resp
+	case errors.As(err, &actionErr):
+		resp.Error = &api.ErrorBody{Code: actionErr.Code, Message: actionErr.Message}
+		return http.StatusUnprocessableEntity, resp
+	case err != nil:
+		resp.Error = &api.ErrorBody{Code: "processing_error", Message: err.Error()}
+		return http.StatusInternalServerError, resp
+	}
+	return http.StatusOK, resp
+}
+
+// planSteps convierte los pasos de un plan, o las llamadas del agente, al
+// formato de la API.
+func planSteps(steps []coordinator.StepResult) []api.PlanStep {
+	var converted []api.PlanStep
+	for _, step := range steps {
+		planStep := api.PlanStep{
+			ID:          step.ID,
+			Action:      step.Action,
+			Params:      step.Params,
+			DependsOn:   step.DependsOn,
+			Status:      step.Status,
+			Error:       step.Error,
+			ExecutionMs: api.Millis(step.ExecutionTime),
+		}
+		if step.Response != nil {
+			planStep.Message = step.Response.Message
+			planStep.Data = step.Response.Data
+		}
+		converted = append(converted, planStep)
+	}
+	return converted
+}
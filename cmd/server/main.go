@@ -1,38 +1,53 @@
 package main
 
 import (
-	"net/http"
+	"context"
+	"log"
 
 	"github.com/ivanneira/Lapislazuli/config"
+	"github.com/ivanneira/Lapislazuli/internal/api"
 	"github.com/ivanneira/Lapislazuli/internal/coordinator"
+	"github.com/ivanneira/Lapislazuli/internal/mcp"
+	"github.com/ivanneira/Lapislazuli/internal/mcpproto"
 
 	"github.com/gin-gonic/gin"
 )
 
-// RequestPayload representa el JSON de entrada.
-type RequestPayload struct {
-	Text string `json:"text"`
-}
+// mcpServerName es el nombre con el que se presenta el servidor MCP.
+const mcpServerName = "lapislazuli"
 
 func main() {
 	// Cargar configuración desde .env
 	config.LoadConfig()
 
+	// Registrar las acciones: ejecutables, incorporadas y de servidores MCP externos
+	closeServers := coordinator.LoadActions(context.Background(),
+		mcpproto.Implementation{Name: mcpServerName, Version: api.Version})
+	defer closeServers()
+
+	store, err := mcp.OpenStore(config.Config.SessionStore, config.Config.SessionPath)
+	if err != nil {
+		log.Fatalf("No se pudo abrir el session store: %v", err)
+	}
+	sessions := mcp.NewSessionManager(store, config.Config.SessionTTL)
+	sessions.StartJanitor(config.Config.SessionJanitorInterval)
+	defer sessions.Close()
+
+	client := mcp.NewAIClient(coordinator.NewService(sessions))
+
 	router := gin.Default()
 	// Ruta configurada como /index
-	router.POST("/index", func(c *gin.Context) {
-		var payload RequestPayload
-		if err := c.ShouldBindJSON(&payload); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
-		// Llama al coordinador para procesar el prompt
-		if err := coordinator.HandlePrompt(payload.Text); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		c.JSON(http.StatusOK, gin.H{"message": "procesado"})
-	})
+	router.POST("/index", indexHandler(client))
+	// Igual que /index pero emitiendo el progreso como Server-Sent Events
+	router.POST("/index/stream", streamHandler(client))
+	if config.Config.MCPHTTP && config.Config.MCPToken == "" {
+		// Cualquiera en la red podría ejecutar acciones: sin token no se expone
+		log.Printf("MCP_HTTP requiere MCP_TOKEN; no se atiende /mcp")
+	} else if config.Config.MCPHTTP {
+		// Las acciones como herramientas del Model Context Protocol
+		server := mcpproto.NewServer(mcpServerName, api.Version, coordinator.NewTools())
+		router.Any("/mcp", gin.WrapH(mcpproto.HTTPHandler(server, config.Config.MCPAllowedOrigins, config.Config.MCPToken)))
+	}
 
 	router.Run(":8080")
 }
@@ -5,27 +5,82 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+
+	"github.com/ivanneira/Lapislazuli/internal/actions"
+	"github.com/ivanneira/Lapislazuli/internal/examples"
 )
 
 // ConfigStruct almacena las variables de entorno.
 type ConfigStruct struct {
-	ServerURL                      string
-	ClassificatorAPIKey            string
-	ClassificatorModelName         string
-	ClassificatorLMAPIURL          string
-	Actions                        []string
-	ClassificatorTemperature       float32
-	ClassificatorMaxTokens         int
-	ClassificatorTopK              int
-	ClassificatorTopP              float32
-	ClassificatorMinP              float32
-	ClassificatorRepetitionPenalty float32
+	ServerURL              string
+	RequestTimeout         time.Duration
+	Locale                 string
+	ActionsDir             string
+	Actions                []string
+	Manifests              []actions.Manifest
+	ActionTimeout          time.Duration
+	ActionLimits           actions.Limits
+	BuiltinActions         []string
+	WebhookURL             string
+	WebhookAPIKey          string
+	WebhookDescription     string
+	ReplyMode              string
+	ExamplesFile           string
+	Examples               *examples.Store
+	FewShot                string
+	FewShotK               int
+	Router                 bool
+	RouterThreshold        float64
+	RouterCache            string
+	ConfidenceThreshold    float64
+	Planner                bool
+	PlanMaxSteps           int
+	PlanOnFailure          string
+	Agent                  bool
+	AgentMaxIterations     int
+	MCPHTTP                bool
+	MCPAllowedOrigins      []string
+	MCPToken               string
+	MCPServers             []MCPServer
+	ClassificationLogprobs int
+	SessionStore           string
+	SessionPath            string
+	SessionTTL             time.Duration
+	SessionJanitorInterval time.Duration
+	ContextStrategy        string
+	LMRetryAttempts        int
+	LMRetryBaseDelay       time.Duration
+	LMRetryMaxDelay        time.Duration
+	LMBreakerThreshold     int
+	LMBreakerCooldown      time.Duration
+	Models                 map[string]ModelProfile
+	Roles                  map[string]string
 }
 
 var Config ConfigStruct
 
+// Cómo elegir los ejemplos few-shot del clasificador.
+const (
+	FewShotAll  = "all"  // todos los ejemplos
+	FewShotBM25 = "bm25" // los FEW_SHOT_K más parecidos al prompt
+	FewShotOff  = "off"  // ninguno
+)
+
+// Qué hacer cuando falla un paso del plan.
+const (
+	PlanStop       = "stop"       // no se ejecutan los pasos siguientes
+	PlanCompensate = "compensate" // además se deshacen los pasos ya hechos que declaran compensate
+)
+
+// Qué hacer con los mensajes viejos que no entran en la ventana de contexto.
+const (
+	ContextTruncate  = "truncate"  // se descartan
+	ContextSummarize = "summarize" // se resumen con el modelo del rol summarizer
+)
+
 // Función genérica para obtener valores del entorno
 func getEnvValue[T any](key string, parser func(string) (T, error), defaultValue T) T {
 	if val := os.Getenv(key); val != "" {
@@ -42,6 +97,56 @@ func parseFloat32(s string) (float32, error) {
 	return float32(v), err
 }
 
+func parseFloat64(s string) (float64, error) {
+	return strconv.ParseFloat(s, 64)
+}
+
+func parseUint(s string) (uint64, error) {
+	return strconv.ParseUint(s, 10, 64)
+}
+
+// envOneOf devuelve el valor de key si es uno de allowed, o defaultValue si
+// no está definido. Un valor desconocido se informa y usa defaultValue.
+func envOneOf(key, defaultValue string, allowed ...string) string {
+	val := os.Getenv(key)
+	if val == "" {
+		return defaultValue
+	}
+	for _, a := range allowed {
+		if val == a {
+			return val
+		}
+	}
+	log.Printf("Valor inválido para %s: %q (se esperaba uno de %v); se usa %s", key, val, allowed, defaultValue)
+	return defaultValue
+}
+
+// envPositiveInt devuelve el valor entero de key si es mayor que cero, o
+// defaultValue si no está definido. Un valor inválido se informa y usa
+// defaultValue.
+func envPositiveInt(key string, defaultValue int) int {
+	val := os.Getenv(key)
+	if val == "" {
+		return defaultValue
+	}
+	if n, err := strconv.Atoi(val); err == nil && n > 0 {
+		return n
+	}
+	log.Printf("Valor inválido para %s: %q (se esperaba un entero mayor que 0); se usa %d", key, val, defaultValue)
+	return defaultValue
+}
+
+// splitList separa una lista de valores separados por comas, sin vacíos.
+func splitList(s string) []string {
+	var values []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
 // LoadConfig carga la configuración desde el archivo .env.
 func LoadConfig() {
 	if err := godotenv.Load(); err != nil {
@@ -49,20 +154,119 @@ func LoadConfig() {
 	}
 
 	Config.ServerURL = os.Getenv("SERVER_URL")
-	Config.ClassificatorAPIKey = os.Getenv("CLASSIFICATOR_API_KEY")
-	Config.ClassificatorModelName = os.Getenv("CLASSIFICATOR_MODEL_NAME")
-	Config.ClassificatorLMAPIURL = os.Getenv("CLASSIFICATOR_LM_API_URL")
+	Config.RequestTimeout = getEnvValue("REQUEST_TIMEOUT", time.ParseDuration, 0)
+	Config.Locale = os.Getenv("LOCALE")
+	if Config.Locale == "" {
+		Config.Locale = "es-AR"
+	}
+
+	Config.ActionsDir = os.Getenv("ACTIONS_DIR")
+	if Config.ActionsDir == "" {
+		Config.ActionsDir = "actions"
+	}
+	Config.Manifests = loadManifests(Config.ActionsDir, os.Getenv("ACTIONS"))
+	Config.Actions = actions.Names(Config.Manifests)
+	Config.ActionTimeout = getEnvValue("ACTION_TIMEOUT", time.ParseDuration, 30*time.Second)
+	Config.ActionLimits = actions.Limits{
+		CPUSeconds: getEnvValue("ACTION_LIMIT_CPU_SECONDS", parseUint, 0),
+		MemoryMB:   getEnvValue("ACTION_LIMIT_MEMORY_MB", parseUint, 0),
+		OpenFiles:  getEnvValue("ACTION_LIMIT_OPEN_FILES", parseUint, 0),
+	}
+	Config.BuiltinActions = splitList(os.Getenv("BUILTIN_ACTIONS"))
+	Config.WebhookURL = os.Getenv("WEBHOOK_URL")
+	Config.WebhookAPIKey = os.Getenv("WEBHOOK_API_KEY")
+	Config.WebhookDescription = os.Getenv("WEBHOOK_DESCRIPTION")
+
+	Config.ReplyMode = os.Getenv("REPLY_MODE")
+	if Config.ReplyMode == "" {
+		Config.ReplyMode = actions.ReplyLLM
+	}
+
+	Config.ExamplesFile = os.Getenv("EXAMPLES_FILE")
+	store, err := examples.Load(Config.Manifests, Config.ExamplesFile)
+	if err != nil {
+		log.Printf("No se pudieron leer los ejemplos de %s: %v", Config.ExamplesFile, err)
+	}
+	Config.Examples = store
+	Config.FewShot = os.Getenv("FEW_SHOT")
+	if Config.FewShot == "" {
+		Config.FewShot = FewShotAll
+	}
+	Config.FewShotK = getEnvValue("FEW_SHOT_K", strconv.Atoi, 5)
+	Config.Router = getEnvValue("ROUTER", strconv.ParseBool, false)
+	Config.RouterThreshold = getEnvValue("ROUTER_THRESHOLD", parseFloat64, 0.85)
+	Config.RouterCache = os.Getenv("ROUTER_CACHE")
+	if Config.RouterCache == "" {
+		Config.RouterCache = "embeddings.json"
+	}
+
+	Config.ConfidenceThreshold = getEnvValue("CLASSIFICATION_THRESHOLD", parseFloat64, 0)
+	Config.ClassificationLogprobs = getEnvValue("CLASSIFICATION_LOGPROBS", strconv.Atoi, 0)
+	Config.Planner = getEnvValue("PLANNER", strconv.ParseBool, false)
+	Config.PlanMaxSteps = getEnvValue("PLAN_MAX_STEPS", strconv.Atoi, 5)
+	Config.PlanOnFailure = envOneOf("PLAN_ON_FAILURE", PlanCompensate, PlanStop, PlanCompensate)
+	Config.Agent = getEnvValue("AGENT", strconv.ParseBool, false)
+	Config.AgentMaxIterations = envPositiveInt("AGENT_MAX_ITERATIONS", 5)
+	Config.MCPHTTP = getEnvValue("MCP_HTTP", strconv.ParseBool, false)
+	Config.MCPAllowedOrigins = splitList(os.Getenv("MCP_ALLOWED_ORIGINS"))
+	Config.MCPToken = os.Getenv("MCP_TOKEN")
+	Config.MCPServers = loadMCPServers()
 
-	if actions := os.Getenv("ACTIONS"); actions != "" {
-		Config.Actions = strings.Split(actions, ",")
+	Config.SessionStore = os.Getenv("SESSION_STORE")
+	if Config.SessionStore == "" {
+		Config.SessionStore = "memory"
+	}
+	Config.SessionPath = os.Getenv("SESSION_PATH")
+	Config.SessionTTL = getEnvValue("SESSION_TTL", time.ParseDuration, 24*time.Hour)
+	Config.SessionJanitorInterval = getEnvValue("SESSION_JANITOR_INTERVAL", time.ParseDuration, 10*time.Minute)
+	Config.ContextStrategy = os.Getenv("CONTEXT_STRATEGY")
+	if Config.ContextStrategy == "" {
+		Config.ContextStrategy = ContextTruncate
+	}
+
+	Config.LMRetryAttempts = getEnvValue("LM_RETRY_ATTEMPTS", strconv.Atoi, 3)
+	Config.LMRetryBaseDelay = getEnvValue("LM_RETRY_BASE_DELAY", time.ParseDuration, 500*time.Millisecond)
+	Config.LMRetryMaxDelay = getEnvValue("LM_RETRY_MAX_DELAY", time.ParseDuration, 10*time.Second)
+	Config.LMBreakerThreshold = getEnvValue("LM_BREAKER_THRESHOLD", strconv.Atoi, 5)
+	Config.LMBreakerCooldown = getEnvValue("LM_BREAKER_COOLDOWN", time.ParseDuration, 30*time.Second)
+
+	loadModels()
+}
+
+// loadManifests carga los manifiestos de acciones. Si ACTIONS está definido se
+// usa como lista de acciones habilitadas; las que no tengan manifiesto se
+// ejecutan como <dir>/<nombre> (<nombre>.exe en Windows), igual que antes de
+// existir los manifiestos.
+func loadManifests(dir, allowed string) []actions.Manifest {
+	manifests, err := actions.LoadManifests(dir)
+	if err != nil {
+		log.Printf("No se pudieron leer los manifiestos de %s: %v", dir, err)
+	}
+
+	var names []string
+	if allowed != "" {
+		names = strings.Split(allowed, ",")
+	} else if len(manifests) == 0 {
+		names = []string{"llamada", "mensaje", "correo"}
 	} else {
-		Config.Actions = []string{"llamada", "mensaje", "correo"}
+		return manifests
 	}
 
-	Config.ClassificatorTemperature = getEnvValue("CLASSIFICATOR_LM_TEMPERATURE", parseFloat32, -1)
-	Config.ClassificatorMaxTokens = getEnvValue("CLASSIFICATOR_LM_MAX_TOKENS", strconv.Atoi, -1)
-	Config.ClassificatorTopK = getEnvValue("CLASSIFICATOR_LM_TOP_K", strconv.Atoi, -1)
-	Config.ClassificatorTopP = getEnvValue("CLASSIFICATOR_LM_TOP_P", parseFloat32, -1)
-	Config.ClassificatorMinP = getEnvValue("CLASSIFICATOR_LM_MIN_P", parseFloat32, -1)
-	Config.ClassificatorRepetitionPenalty = getEnvValue("CLASSIFICATOR_LM_REPETITION_PENALTY", parseFloat32, -1)
+	enabled := make([]actions.Manifest, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if name == actions.None {
+			log.Printf("Se ignora la acción %s: el nombre está reservado", name)
+			continue
+		}
+		if m, ok := actions.Find(manifests, name); ok {
+			enabled = append(enabled, *m)
+		} else {
+			enabled = append(enabled, actions.Legacy(dir, name))
+		}
+	}
+	return enabled
 }
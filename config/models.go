@@ -0,0 +1,151 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Roles a los que se asigna un perfil de modelo.
+const (
+	RoleClassifier = "classifier"
+	RoleResponder  = "responder"
+	RoleSummarizer = "summarizer"
+	RoleEmbedder   = "embedder"
+	RoleAgent      = "agent"
+)
+
+// DefaultContextTokens es la ventana de contexto de los perfiles que no la
+// declaran.
+const DefaultContextTokens = 4096
+
+// Perfiles que se arman a partir de las variables CLASSIFICATOR_* y RESPONSE_*.
+const (
+	ProfileClassificator = "classificator"
+	ProfileResponse      = "response"
+)
+
+// ModelProfile describe un modelo: dónde está, con qué API hablarle, cómo
+// autenticarse y con qué parámetros de muestreo llamarlo. Los parámetros en
+// -1 no se envían. StructuredOutput elige cómo restringir las respuestas
+// estructuradas: json_schema o grammar (GBNF); vacío usa lo propio de la API.
+// StrictSchema pide el modo strict de OpenAI para las respuestas
+// estructuradas y las herramientas. Fallback es el perfil a usar cuando este
+// no responde. ContextTokens es el tamaño de la ventana de contexto del
+// modelo, en tokens.
+type ModelProfile struct {
+	Name              string
+	Provider          string
+	StructuredOutput  string
+	StrictSchema      bool
+	Fallback          string
+	URL               string
+	APIKey            string
+	Model             string
+	Temperature       float32
+	MaxTokens         int
+	TopK              int
+	TopP              float32
+	MinP              float32
+	RepetitionPenalty float32
+	Timeout           time.Duration
+	ContextTokens     int
+}
+
+// loadProfile lee un perfil de las variables <prefix>_*. modelKey y urlKey
+// permiten respetar los nombres históricos (CLASSIFICATOR_MODEL_NAME,
+// CLASSIFICATOR_LM_API_URL, ...).
+func loadProfile(name, prefix, modelKey, urlKey, paramPrefix string) ModelProfile {
+	return ModelProfile{
+		Name:              name,
+		Provider:          strings.ToLower(os.Getenv(prefix + "_PROVIDER")),
+		StructuredOutput:  strings.ToLower(os.Getenv(prefix + "_STRUCTURED_OUTPUT")),
+		StrictSchema:      getEnvValue(prefix+"_STRICT_SCHEMA", strconv.ParseBool, false),
+		Fallback:          os.Getenv(prefix + "_FALLBACK"),
+		URL:               os.Getenv(urlKey),
+		APIKey:            os.Getenv(prefix + "_API_KEY"),
+		Model:             os.Getenv(modelKey),
+		Temperature:       getEnvValue(paramPrefix+"_TEMPERATURE", parseFloat32, -1),
+		MaxTokens:         getEnvValue(paramPrefix+"_MAX_TOKENS", strconv.Atoi, -1),
+		TopK:              getEnvValue(paramPrefix+"_TOP_K", strconv.Atoi, -1),
+		TopP:              getEnvValue(paramPrefix+"_TOP_P", parseFloat32, -1),
+		MinP:              getEnvValue(paramPrefix+"_MIN_P", parseFloat32, -1),
+		RepetitionPenalty: getEnvValue(paramPrefix+"_REPETITION_PENALTY", parseFloat32, -1),
+		Timeout:           getEnvValue(prefix+"_TIMEOUT", time.ParseDuration, 0),
+		ContextTokens:     getEnvValue(prefix+"_CONTEXT_TOKENS", strconv.Atoi, DefaultContextTokens),
+	}
+}
+
+// loadModels arma el registro de modelos. Los perfiles "classificator" y
+// "response" salen de las variables CLASSIFICATOR_* y RESPONSE_*; MODELS
+// declara perfiles adicionales con variables MODEL_<NOMBRE>_*. ROLE_<ROL>
+// asigna un perfil a cada rol.
+func loadModels() {
+	Config.Models = map[string]ModelProfile{
+		ProfileClassificator: loadProfile(ProfileClassificator, "CLASSIFICATOR",
+			"CLASSIFICATOR_MODEL_NAME", "CLASSIFICATOR_LM_API_URL", "CLASSIFICATOR_LM"),
+		ProfileResponse: loadProfile(ProfileResponse, "RESPONSE",
+			"RESPONSE_MODEL_NAME", "RESPONSE_LM_API_URL", "RESPONSE_LM"),
+	}
+
+	for _, name := range strings.Split(os.Getenv("MODELS"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		prefix := "MODEL_" + strings.ToUpper(name)
+		Config.Models[name] = loadProfile(name, prefix, prefix+"_NAME", prefix+"_URL", prefix)
+	}
+
+	Config.Roles = map[string]string{
+		RoleClassifier: envOr("ROLE_CLASSIFIER", ProfileClassificator),
+		RoleResponder:  envOr("ROLE_RESPONDER", ProfileResponse),
+	}
+	Config.Roles[RoleSummarizer] = envOr("ROLE_SUMMARIZER", Config.Roles[RoleResponder])
+	Config.Roles[RoleEmbedder] = envOr("ROLE_EMBEDDER", Config.Roles[RoleClassifier])
+	Config.Roles[RoleAgent] = envOr("ROLE_AGENT", Config.Roles[RoleResponder])
+
+	for role, name := range Config.Roles {
+		if _, ok := Config.Models[name]; !ok {
+			log.Printf("El rol %s usa el perfil de modelo %s, que no está definido", role, name)
+		}
+	}
+	for name, profile := range Config.Models {
+		if _, ok := Config.Models[profile.Fallback]; profile.Fallback != "" && !ok {
+			log.Printf("El perfil de modelo %s usa como respaldo %s, que no está definido", name, profile.Fallback)
+		}
+	}
+}
+
+// Profile devuelve el perfil de modelo con ese nombre.
+func Profile(name string) (ModelProfile, bool) {
+	profile, ok := Config.Models[name]
+	return profile, ok
+}
+
+// Model devuelve el perfil de modelo asignado a un rol. Si el rol no tiene
+// perfil devuelve uno vacío con los parámetros sin definir.
+func Model(role string) ModelProfile {
+	if profile, ok := Config.Models[Config.Roles[role]]; ok {
+		return profile
+	}
+	return ModelProfile{
+		Name:              Config.Roles[role],
+		Temperature:       -1,
+		MaxTokens:         -1,
+		TopK:              -1,
+		TopP:              -1,
+		MinP:              -1,
+		RepetitionPenalty: -1,
+		ContextTokens:     DefaultContextTokens,
+	}
+}
+
+func envOr(key, defaultValue string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
+	}
+	return defaultValue
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strings"
+
+	"github.com/ivanneira/Lapislazuli/internal/actions"
+	"github.com/ivanneira/Lapislazuli/internal/examples"
+)
+
+// MCPServer describe un servidor MCP externo cuyas herramientas se usan como
+// acciones. Se lanza como proceso con Command y se le habla por stdio, o se
+// le habla por HTTP en URL. APIKey se envía como token Bearer. Prefix se
+// antepone al nombre de cada herramienta para evitar choques con otras
+// acciones. Si Tools no está vacío, solo se usan esas herramientas.
+type MCPServer struct {
+	Name    string
+	Command []string
+	URL     string
+	APIKey  string
+	Prefix  string
+	Tools   []string
+}
+
+// loadMCPServers lee los servidores declarados en MCP_SERVERS, cada uno con
+// variables MCP_SERVER_<NOMBRE>_*. Los que no declaran COMMAND ni URL, o
+// declaran los dos, se informan y se omiten.
+func loadMCPServers() []MCPServer {
+	var servers []MCPServer
+	for _, name := range splitList(os.Getenv("MCP_SERVERS")) {
+		prefix := "MCP_SERVER_" + strings.ToUpper(name)
+		server := MCPServer{
+			Name:    name,
+			Command: strings.Fields(os.Getenv(prefix + "_COMMAND")),
+			URL:     os.Getenv(prefix + "_URL"),
+			APIKey:  os.Getenv(prefix + "_API_KEY"),
+			Prefix:  os.Getenv(prefix + "_PREFIX"),
+			Tools:   splitList(os.Getenv(prefix + "_TOOLS")),
+		}
+		if (len(server.Command) == 0) == (server.URL == "") {
+			log.Printf("Se ignora el servidor MCP %s: tiene que declarar %s_COMMAND o %s_URL", name, prefix, prefix)
+			continue
+		}
+		servers = append(servers, server)
+	}
+	return servers
+}
+
+// AddManifests suma acciones a las configuradas, como las incorporadas o las
+// herramientas de los servidores MCP externos, y vuelve a cargar los ejemplos
+// para incluirlas.
+// Las que chocan con una acción existente se informan y se omiten. Tiene que
+// llamarse antes de empezar a atender peticiones.
+func AddManifests(manifests []actions.Manifest) {
+	added := 0
+	for _, m := range manifests {
+		if _, ok := actions.Find(Config.Manifests, m.Name); ok || m.Name == actions.None {
+			log.Printf("Se ignora la acción %s: ya hay otra con ese nombre", m.Name)
+			continue
+		}
+		Config.Manifests = append(Config.Manifests, m)
+		added++
+	}
+	if added == 0 {
+		return
+	}
+	Config.Actions = actions.Names(Config.Manifests)
+
+	store, err := examples.Load(Config.Manifests, Config.ExamplesFile)
+	if err != nil {
+		log.Printf("No se pudieron leer los ejemplos de %s: %v", Config.ExamplesFile, err)
+	}
+	Config.Examples = store
+}
@@ -0,0 +1,88 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ivanneira/Lapislazuli/pkg/actionsdk"
+)
+
+// Request es lo que recibe una acción: el prompt, los parámetros validados y
+// los datos de la sesión. Es el mismo sobre que reciben los ejecutables.
+type Request = actionsdk.Request
+
+// Result es lo que devuelve una acción, con la misma forma que la respuesta
+// de un ejecutable.
+type Result = actionsdk.Response
+
+// Action es la implementación de una acción: el ejecutable de un manifiesto,
+// una herramienta de un servidor MCP externo o una acción escrita en Go que
+// corre dentro del proceso.
+type Action interface {
+	// Name es el nombre con el que la eligen el clasificador y los planes.
+	Name() string
+	// Describe explica qué hace la acción, para el modelo.
+	Describe() string
+	// Schema es el JSON Schema del objeto de parámetros.
+	Schema() map[string]interface{}
+	// Execute ejecuta la acción con los parámetros ya validados. Que la acción
+	// falle se informa con un Result con status error; el error es para
+	// cuando no se pudo ejecutar. Tiene que respetar la cancelación de ctx.
+	Execute(ctx context.Context, req Request) (*Result, error)
+}
+
+// FromAction arma el manifiesto de una acción que no tiene archivo de
+// manifiesto. Conserva su Schema tal cual como InputSchema; Parameters solo
+// lo describe para el modelo.
+func FromAction(a Action) (Manifest, error) {
+	schema := a.Schema()
+	if t, ok := schema["type"]; ok && t != "object" {
+		return Manifest{}, fmt.Errorf("el esquema de parámetros de %s no es un objeto", a.Name())
+	}
+	return Manifest{
+		Name:        a.Name(),
+		Description: a.Describe(),
+		Parameters:  schemaParameters(schema),
+		InputSchema: schema,
+	}, nil
+}
+
+// Registry guarda la implementación de cada acción por nombre.
+type Registry struct {
+	mu      sync.RWMutex
+	actions map[string]Action
+}
+
+// NewRegistry crea un registro vacío.
+func NewRegistry() *Registry {
+	return &Registry{actions: make(map[string]Action)}
+}
+
+// Register agrega una acción. Falla si el nombre está reservado o ya hay otra
+// acción con ese nombre.
+func (r *Registry) Register(a Action) error {
+	name := a.Name()
+	if name == "" {
+		return fmt.Errorf("la acción no tiene nombre")
+	}
+	if name == None {
+		return fmt.Errorf("el nombre de acción %q está reservado", None)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.actions[name]; ok {
+		return fmt.Errorf("ya hay una acción llamada %s", name)
+	}
+	r.actions[name] = a
+	return nil
+}
+
+// Get devuelve la acción con ese nombre.
+func (r *Registry) Get(name string) (Action, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	a, ok := r.actions[name]
+	return a, ok
+}
@@ -0,0 +1,281 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/ivanneira/Lapislazuli/internal/logger"
+)
+
+// None es la acción que devuelve el clasificador cuando el prompt no
+// corresponde a ninguna acción. Ningún manifiesto puede usar este nombre.
+const None = "none"
+
+// Formas de pasar los parámetros al ejecutable.
+const (
+	ParamsStdin = "stdin" // solo dentro del sobre JSON de stdin (por defecto)
+	ParamsFlags = "flags" // además como argumentos --nombre=valor
+)
+
+// ExecSpec describe cómo lanzar el ejecutable de una acción.
+type ExecSpec struct {
+	Path     string   `json:"path" yaml:"path"`
+	Args     []string `json:"args,omitempty" yaml:"args,omitempty"`
+	ParamsAs string   `json:"params_as,omitempty" yaml:"params_as,omitempty"`
+}
+
+// Limits define límites de recursos para el proceso de una acción. Un valor 0
+// significa sin límite. Solo se aplican en Linux.
+type Limits struct {
+	CPUSeconds uint64 `json:"cpu_seconds,omitempty" yaml:"cpu_seconds,omitempty"`
+	MemoryMB   uint64 `json:"memory_mb,omitempty" yaml:"memory_mb,omitempty"`
+	OpenFiles  uint64 `json:"open_files,omitempty" yaml:"open_files,omitempty"`
+}
+
+// Merge completa los límites no declarados con los valores por defecto.
+func (l Limits) Merge(defaults Limits) Limits {
+	if l.CPUSeconds == 0 {
+		l.CPUSeconds = defaults.CPUSeconds
+	}
+	if l.MemoryMB == 0 {
+		l.MemoryMB = defaults.MemoryMB
+	}
+	if l.OpenFiles == 0 {
+		l.OpenFiles = defaults.OpenFiles
+	}
+	return l
+}
+
+// IsZero indica si no hay ningún límite definido.
+func (l Limits) IsZero() bool {
+	return l == Limits{}
+}
+
+// Modos de generar la respuesta al usuario después de ejecutar la acción.
+const (
+	ReplyLLM      = "llm"      // con el modelo de respuestas
+	ReplyTemplate = "template" // con una plantilla text/template
+	ReplyRaw      = "raw"      // el mensaje del ejecutable tal cual
+)
+
+// ReplySpec configura cómo se responde al usuario después de la acción.
+type ReplySpec struct {
+	Mode     string `json:"mode,omitempty" yaml:"mode,omitempty"`
+	Template string `json:"template,omitempty" yaml:"template,omitempty"`
+}
+
+// Parameter describe un parámetro que la acción acepta.
+type Parameter struct {
+	Name        string   `json:"name" yaml:"name"`
+	Type        string   `json:"type" yaml:"type"`
+	Description string   `json:"description,omitempty" yaml:"description,omitempty"`
+	Required    bool     `json:"required,omitempty" yaml:"required,omitempty"`
+	Enum        []string `json:"enum,omitempty" yaml:"enum,omitempty"`
+}
+
+// Manifest declara una acción: qué hace, cómo se ejecuta y qué parámetros recibe.
+type Manifest struct {
+	Name        string      `json:"name" yaml:"name"`
+	Description string      `json:"description" yaml:"description"`
+	Examples    []string    `json:"examples,omitempty" yaml:"examples,omitempty"`
+	Exec        ExecSpec    `json:"exec" yaml:"exec"`
+	Timeout     string      `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	Limits      Limits      `json:"limits,omitempty" yaml:"limits,omitempty"`
+	Reply       ReplySpec   `json:"reply,omitempty" yaml:"reply,omitempty"`
+	Parameters  []Parameter `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	// Compensate es la acción que deshace esta cuando falla un paso
+	// posterior del plan; recibe los mismos parámetros.
+	Compensate string `json:"compensate,omitempty" yaml:"compensate,omitempty"`
+	// InputSchema es el JSON Schema original de los parámetros de las
+	// acciones que no vienen de un archivo, como las herramientas de un
+	// servidor MCP. Si está, manda sobre Parameters para el esquema y la
+	// validación, y los argumentos se pasan sin recortar.
+	InputSchema map[string]interface{} `json:"-" yaml:"-"`
+
+	// Directorio del archivo de manifiesto, usado para resolver rutas relativas.
+	dir string
+}
+
+// TimeoutDuration devuelve el timeout declarado, o 0 si no hay ninguno.
+func (m *Manifest) TimeoutDuration() time.Duration {
+	if m.Timeout == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(m.Timeout)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+// ExecPath devuelve la ruta del ejecutable resuelta respecto del manifiesto.
+// En Windows se agrega la extensión .exe si la ruta no tiene ninguna.
+func (m *Manifest) ExecPath() string {
+	path := m.Exec.Path
+	if !filepath.IsAbs(path) && m.dir != "" {
+		path = filepath.Join(m.dir, path)
+	}
+	if runtime.GOOS == "windows" && filepath.Ext(path) == "" {
+		path += ".exe"
+	}
+	return path
+}
+
+// RenderReply ejecuta la plantilla de respuesta de la acción con data.
+func (m *Manifest) RenderReply(data interface{}) (string, error) {
+	tmpl, err := template.New(m.Name).Parse(m.Reply.Template)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// validate verifica los campos obligatorios del manifiesto.
+func (m *Manifest) validate() error {
+	if m.Name == "" {
+		return fmt.Errorf("el manifiesto no tiene nombre")
+	}
+	if m.Name == None {
+		return fmt.Errorf("el nombre de acción %q está reservado", None)
+	}
+	if m.Exec.Path == "" {
+		return fmt.Errorf("la acción %s no declara exec.path", m.Name)
+	}
+	switch m.Exec.ParamsAs {
+	case "", ParamsStdin, ParamsFlags:
+	default:
+		return fmt.Errorf("exec.params_as inválido en la acción %s: %s", m.Name, m.Exec.ParamsAs)
+	}
+	for _, p := range m.Parameters {
+		if p.Name == "" {
+			return fmt.Errorf("la acción %s tiene un parámetro sin nombre", m.Name)
+		}
+		if !validType(p.paramType()) {
+			return fmt.Errorf("tipo %q no soportado en el parámetro %s de la acción %s", p.Type, p.Name, m.Name)
+		}
+	}
+	switch m.Reply.Mode {
+	case "", ReplyLLM, ReplyRaw:
+	case ReplyTemplate:
+		if _, err := template.New(m.Name).Parse(m.Reply.Template); err != nil {
+			return fmt.Errorf("plantilla de respuesta inválida en la acción %s: %v", m.Name, err)
+		}
+	default:
+		return fmt.Errorf("reply.mode inválido en la acción %s: %s", m.Name, m.Reply.Mode)
+	}
+	if m.Compensate == m.Name {
+		return fmt.Errorf("la acción %s no puede compensarse a sí misma", m.Name)
+	}
+	if m.Timeout != "" {
+		if _, err := time.ParseDuration(m.Timeout); err != nil {
+			return fmt.Errorf("timeout inválido en la acción %s: %v", m.Name, err)
+		}
+	}
+	return nil
+}
+
+// LoadManifest lee un manifiesto JSON o YAML según la extensión del archivo.
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var m Manifest
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, &m)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &m)
+	default:
+		return nil, fmt.Errorf("formato de manifiesto no soportado: %s", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error leyendo %s: %v", path, err)
+	}
+
+	m.dir = filepath.Dir(path)
+	if err := m.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return &m, nil
+}
+
+// LoadManifests carga todos los manifiestos de un directorio, ordenados por nombre.
+// Los manifiestos inválidos o duplicados se informan y se omiten.
+func LoadManifests(dir string) ([]Manifest, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	manifests := make([]Manifest, 0)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".json", ".yaml", ".yml":
+		default:
+			continue
+		}
+
+		m, err := LoadManifest(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			logger.Warn("Manifiesto omitido: %v", err)
+			continue
+		}
+		if seen[m.Name] {
+			logger.Warn("Acción duplicada omitida: %s (%s)", m.Name, entry.Name())
+			continue
+		}
+		seen[m.Name] = true
+		manifests = append(manifests, *m)
+	}
+
+	sort.Slice(manifests, func(i, j int) bool { return manifests[i].Name < manifests[j].Name })
+	return manifests, nil
+}
+
+// Legacy crea el manifiesto implícito de una acción declarada solo en ACTIONS,
+// que se ejecuta como <dir>/<nombre> (con .exe en Windows, como en ExecPath).
+func Legacy(dir, name string) Manifest {
+	return Manifest{
+		Name: name,
+		Exec: ExecSpec{Path: name},
+		dir:  dir,
+	}
+}
+
+// Find busca una acción por nombre.
+func Find(manifests []Manifest, name string) (*Manifest, bool) {
+	for i := range manifests {
+		if manifests[i].Name == name {
+			return &manifests[i], true
+		}
+	}
+	return nil, false
+}
+
+// Names devuelve los nombres de las acciones en el orden recibido.
+func Names(manifests []Manifest) []string {
+	names := make([]string, len(manifests))
+	for i, m := range manifests {
+		names[i] = m.Name
+	}
+	return names
+}
@@ -0,0 +1,294 @@
+package actions
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Tipos de parámetro soportados, con la misma semántica que en JSON Schema.
+const (
+	TypeString  = "string"
+	TypeInteger = "integer"
+	TypeNumber  = "number"
+	TypeBoolean = "boolean"
+)
+
+// paramType devuelve el tipo del parámetro, string por defecto.
+func (p Parameter) paramType() string {
+	if p.Type == "" {
+		return TypeString
+	}
+	return p.Type
+}
+
+// ParamsSchema genera el JSON Schema del objeto de parámetros de la acción.
+func (m *Manifest) ParamsSchema() map[string]interface{} {
+	if m.InputSchema != nil {
+		return m.InputSchema
+	}
+	properties := make(map[string]interface{}, len(m.Parameters))
+	required := make([]string, 0)
+	for _, p := range m.Parameters {
+		prop := map[string]interface{}{
+			"type": p.paramType(),
+		}
+		if p.Description != "" {
+			prop["description"] = p.Description
+		}
+		if len(p.Enum) > 0 {
+			prop["enum"] = p.Enum
+		}
+		properties[p.Name] = prop
+		if p.Required {
+			required = append(required, p.Name)
+		}
+	}
+
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+}
+
+// schemaParameters describe las propiedades del JSON Schema de un objeto
+// como parámetros, para listarlas en el prompt. Type solo se completa si la
+// propiedad declara un único tipo: la validación usa el esquema original.
+func schemaParameters(schema map[string]interface{}) []Parameter {
+	properties, _ := schema["properties"].(map[string]interface{})
+	required := make(map[string]bool)
+	for _, name := range stringList(schema["required"]) {
+		required[name] = true
+	}
+
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	params := make([]Parameter, 0, len(names))
+	for _, name := range names {
+		prop, _ := properties[name].(map[string]interface{})
+		p := Parameter{Name: name, Required: required[name]}
+		p.Type, _ = prop["type"].(string)
+		p.Description, _ = prop["description"].(string)
+		p.Enum = stringList(prop["enum"])
+		params = append(params, p)
+	}
+	return params
+}
+
+// validateSchema verifica params contra el JSON Schema de un objeto: que
+// estén las propiedades obligatorias y que las declaradas respeten type (un
+// tipo o una lista de tipos), enum y anyOf/oneOf (alcanza con cumplir una
+// alternativa). No revisa el contenido de arrays y objetos: eso lo valida
+// quien ejecuta la acción. Los parámetros se devuelven todos, incluidos los
+// no declarados, salvo los opcionales en null que el esquema no admite.
+func validateSchema(schema, params map[string]interface{}) (map[string]interface{}, error) {
+	properties, _ := schema["properties"].(map[string]interface{})
+	required := make(map[string]bool)
+	for _, name := range stringList(schema["required"]) {
+		if _, ok := params[name]; !ok {
+			return nil, fmt.Errorf("falta el parámetro obligatorio %q", name)
+		}
+		required[name] = true
+	}
+	clean := make(map[string]interface{}, len(params))
+	for name, value := range params {
+		if prop, ok := properties[name].(map[string]interface{}); ok {
+			if err := checkSchema(name, prop, value); err != nil {
+				// Un null en un parámetro opcional es que no vino, como lo
+				// devuelven los modelos en modo strict
+				if value == nil && !required[name] {
+					continue
+				}
+				return nil, err
+			}
+		}
+		clean[name] = value
+	}
+	return clean, nil
+}
+
+// checkSchema verifica un valor contra el esquema de una propiedad.
+func checkSchema(name string, prop map[string]interface{}, value interface{}) error {
+	for _, key := range []string{"anyOf", "oneOf"} {
+		alternatives, ok := prop[key].([]interface{})
+		if !ok {
+			continue
+		}
+		matched := false
+		for _, alt := range alternatives {
+			if altSchema, ok := alt.(map[string]interface{}); ok && checkSchema(name, altSchema, value) == nil {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("el parámetro %q no cumple ninguna de las alternativas de %s", name, key)
+		}
+	}
+
+	types := stringList(prop["type"])
+	if t, ok := prop["type"].(string); ok {
+		types = []string{t}
+	}
+	if len(types) > 0 {
+		matched := false
+		for _, t := range types {
+			if matchesType(t, value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("el parámetro %q debe ser de tipo %s", name, strings.Join(types, " o "))
+		}
+	}
+
+	if enum := stringList(prop["enum"]); len(enum) > 0 && !inEnum(enum, value) {
+		return fmt.Errorf("el parámetro %q debe ser uno de %v", name, enum)
+	}
+	return nil
+}
+
+// matchesType indica si un valor decodificado desde JSON es del tipo t de
+// JSON Schema. Los tipos desconocidos se aceptan.
+func matchesType(t string, value interface{}) bool {
+	switch t {
+	case TypeString:
+		_, ok := value.(string)
+		return ok
+	case TypeNumber:
+		_, ok := value.(float64)
+		return ok
+	case TypeInteger:
+		f, ok := value.(float64)
+		return ok && f == math.Trunc(f)
+	case TypeBoolean:
+		_, ok := value.(bool)
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "null":
+		return value == nil
+	}
+	return true
+}
+
+// stringList convierte una lista de un JSON Schema en strings. Acepta tanto
+// la forma decodificada de JSON como la que genera ParamsSchema.
+func stringList(v interface{}) []string {
+	switch list := v.(type) {
+	case []string:
+		return list
+	case []interface{}:
+		values := make([]string, 0, len(list))
+		for _, item := range list {
+			values = append(values, fmt.Sprint(item))
+		}
+		return values
+	}
+	return nil
+}
+
+// ValidateParams verifica que los parámetros extraídos respeten lo declarado en
+// el manifiesto: obligatorios presentes, tipos correctos y valores del enum.
+// Los parámetros no declarados se descartan, salvo que la acción tenga
+// InputSchema: ahí se valida contra ese esquema y se conservan todos.
+func (m *Manifest) ValidateParams(params map[string]interface{}) (map[string]interface{}, error) {
+	if m.InputSchema != nil {
+		return validateSchema(m.InputSchema, params)
+	}
+	clean := make(map[string]interface{}, len(m.Parameters))
+	for _, p := range m.Parameters {
+		value, ok := params[p.Name]
+		if !ok || value == nil {
+			if p.Required {
+				return nil, fmt.Errorf("falta el parámetro obligatorio %q", p.Name)
+			}
+			continue
+		}
+		if err := checkType(p, value); err != nil {
+			return nil, err
+		}
+		if len(p.Enum) > 0 && !inEnum(p.Enum, value) {
+			return nil, fmt.Errorf("el parámetro %q debe ser uno de %v", p.Name, p.Enum)
+		}
+		clean[p.Name] = value
+	}
+	return clean, nil
+}
+
+func validType(t string) bool {
+	switch t {
+	case TypeString, TypeInteger, TypeNumber, TypeBoolean:
+		return true
+	}
+	return false
+}
+
+// checkType verifica el tipo de un valor decodificado desde JSON.
+func checkType(p Parameter, value interface{}) error {
+	ok := false
+	switch p.paramType() {
+	case TypeString:
+		_, ok = value.(string)
+	case TypeNumber:
+		_, ok = value.(float64)
+	case TypeInteger:
+		f, isNum := value.(float64)
+		ok = isNum && f == math.Trunc(f)
+	case TypeBoolean:
+		_, ok = value.(bool)
+	default:
+		return fmt.Errorf("tipo %q no soportado en el parámetro %q", p.Type, p.Name)
+	}
+	if !ok {
+		return fmt.Errorf("el parámetro %q debe ser de tipo %s", p.Name, p.paramType())
+	}
+	return nil
+}
+
+func inEnum(enum []string, value interface{}) bool {
+	for _, e := range enum {
+		if e == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Flags convierte los parámetros en argumentos --nombre=valor, ordenados por nombre.
+func Flags(params map[string]interface{}) []string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	flags := make([]string, 0, len(names))
+	for _, name := range names {
+		flags = append(flags, "--"+name+"="+formatValue(params[name]))
+	}
+	return flags
+}
+
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,103 @@
+package actions
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// fakeAction es una acción sin manifiesto con un esquema fijo.
+type fakeAction struct {
+	schema map[string]interface{}
+}
+
+func (f *fakeAction) Name() string                   { return "buscar" }
+func (f *fakeAction) Describe() string               { return "Busca archivos" }
+func (f *fakeAction) Schema() map[string]interface{} { return f.schema }
+func (f *fakeAction) Execute(ctx context.Context, req Request) (*Result, error) {
+	return nil, nil
+}
+
+func remoteSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"rutas":    map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+			"filtro":   map[string]interface{}{"type": "object"},
+			"limite":   map[string]interface{}{"type": []interface{}{"integer", "null"}},
+			"modo":     map[string]interface{}{"type": "string", "enum": []interface{}{"rapido", "completo"}},
+			"consulta": map[string]interface{}{"anyOf": []interface{}{map[string]interface{}{"type": "string"}, map[string]interface{}{"type": "array"}}},
+		},
+		"required": []interface{}{"rutas"},
+	}
+}
+
+func TestFromActionKeepsSchema(t *testing.T) {
+	schema := remoteSchema()
+	m, err := FromAction(&fakeAction{schema: schema})
+	if err != nil {
+		t.Fatalf("FromAction: %v", err)
+	}
+	if !reflect.DeepEqual(m.ParamsSchema(), schema) {
+		t.Errorf("ParamsSchema = %v, se esperaba el esquema original", m.ParamsSchema())
+	}
+	if len(m.Parameters) != 5 {
+		t.Fatalf("se esperaban 5 parámetros, hay %d", len(m.Parameters))
+	}
+	for _, p := range m.Parameters {
+		if p.Name == "rutas" && !p.Required {
+			t.Errorf("rutas debería ser obligatorio")
+		}
+	}
+
+	if _, err := FromAction(&fakeAction{schema: map[string]interface{}{"type": "string"}}); err == nil {
+		t.Errorf("se esperaba error con un esquema que no es un objeto")
+	}
+}
+
+func TestValidateParamsInputSchema(t *testing.T) {
+	m, err := FromAction(&fakeAction{schema: remoteSchema()})
+	if err != nil {
+		t.Fatalf("FromAction: %v", err)
+	}
+
+	params := map[string]interface{}{
+		"rutas":    []interface{}{"/srv/docs"},
+		"filtro":   map[string]interface{}{"ext": ".md"},
+		"limite":   nil,
+		"modo":     "rapido",
+		"consulta": []interface{}{"a", "b"},
+		"extra":    true,
+	}
+	clean, err := m.ValidateParams(params)
+	if err != nil {
+		t.Fatalf("ValidateParams: %v", err)
+	}
+	if !reflect.DeepEqual(clean, params) {
+		t.Errorf("los parámetros cambiaron: %v", clean)
+	}
+
+	// Un opcional en null, como en el modo strict, es que no vino
+	clean, err = m.ValidateParams(map[string]interface{}{"rutas": []interface{}{}, "modo": nil, "limite": nil})
+	if err != nil {
+		t.Fatalf("ValidateParams con opcionales en null: %v", err)
+	}
+	if _, ok := clean["modo"]; ok || !reflect.DeepEqual(clean["limite"], nil) || len(clean) != 2 {
+		t.Errorf("los null que el esquema no admite deberían descartarse: %v", clean)
+	}
+
+	invalid := []map[string]interface{}{
+		{"rutas": nil},
+		{},
+		{"rutas": "/srv/docs"},
+		{"rutas": []interface{}{}, "filtro": "ext"},
+		{"rutas": []interface{}{}, "limite": 2.5},
+		{"rutas": []interface{}{}, "modo": "lento"},
+		{"rutas": []interface{}{}, "consulta": 3.0},
+	}
+	for _, p := range invalid {
+		if _, err := m.ValidateParams(p); err == nil {
+			t.Errorf("ValidateParams(%v) debería fallar", p)
+		}
+	}
+}
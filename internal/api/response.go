@@ -0,0 +1,119 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// Version identifica la versión del esquema de respuesta de /index.
+// Debe incrementarse ante cualquier cambio incompatible en IndexResponse.
+const Version = "v1"
+
+// RequestIDHeader es el header usado para propagar el ID de la petición.
+const RequestIDHeader = "X-Request-ID"
+
+// Timing agrupa las duraciones de cada etapa, en milisegundos.
+type Timing struct {
+	ClassificationMs int64 `json:"classification_ms"`
+	ExecutionMs      int64 `json:"execution_ms"`
+	ReplyMs          int64 `json:"reply_ms"`
+	TotalMs          int64 `json:"total_ms"`
+}
+
+// Usage agrupa los tokens consumidos por los modelos en la petición.
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// ErrorBody describe un error devuelto por la API.
+type ErrorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Candidate es una acción posible con su confianza, entre 0 y 1.
+type Candidate struct {
+	Action     string  `json:"action"`
+	Confidence float64 `json:"confidence"`
+}
+
+// PlanStep es un paso del plan con su resultado. Status es ok, error,
+// skipped (no se ejecutó porque falló otro paso) o compensated (se deshizo).
+type PlanStep struct {
+	ID          string                 `json:"id"`
+	Action      string                 `json:"action"`
+	Params      map[string]interface{} `json:"params,omitempty"`
+	DependsOn   []string               `json:"depends_on,omitempty"`
+	Status      string                 `json:"status"`
+	Message     string                 `json:"message,omitempty"`
+	Data        map[string]interface{} `json:"data,omitempty"`
+	Error       string                 `json:"error,omitempty"`
+	ExecutionMs int64                  `json:"execution_ms"`
+}
+
+// IndexResponse representa el JSON de salida de /index. Con Clarification
+// no se ejecutó ninguna acción y Reply es una pregunta para que el usuario
+// aclare qué quiere. En modo planificador Plan tiene cada paso; si son
+// varios, Action es "plan" y Message junta los mensajes de todos. En modo
+// agente Action es "agent" y ToolCalls tiene cada acción que llamó el modelo.
+type IndexResponse struct {
+	APIVersion    string                 `json:"api_version"`
+	RequestID     string                 `json:"request_id"`
+	SessionID     string                 `json:"session_id,omitempty"`
+	Action        string                 `json:"action,omitempty"`
+	Params        map[string]interface{} `json:"params,omitempty"`
+	Confidence    float64                `json:"confidence,omitempty"`
+	Candidates    []Candidate            `json:"candidates,omitempty"`
+	Clarification bool                   `json:"clarification,omitempty"`
+	Message       string                 `json:"message,omitempty"`
+	Status        string                 `json:"status,omitempty"`
+	Data          map[string]interface{} `json:"data,omitempty"`
+	FollowUp      []string               `json:"follow_up,omitempty"`
+	Plan          []PlanStep             `json:"plan,omitempty"`
+	ToolCalls     []PlanStep             `json:"tool_calls,omitempty"`
+	Reply         string                 `json:"reply,omitempty"`
+	Timing        Timing                 `json:"timing"`
+	Usage         Usage                  `json:"usage"`
+	Error         *ErrorBody             `json:"error,omitempty"`
+}
+
+// NewResponse crea una respuesta vacía con la versión y el ID de petición.
+func NewResponse(requestID string) IndexResponse {
+	return IndexResponse{
+		APIVersion: Version,
+		RequestID:  requestID,
+	}
+}
+
+// NewError crea una respuesta de error con la versión y el ID de petición.
+func NewError(requestID, code, message string) IndexResponse {
+	resp := NewResponse(requestID)
+	resp.Error = &ErrorBody{Code: code, Message: message}
+	return resp
+}
+
+// NewRequestID genera un identificador aleatorio para la petición.
+func NewRequestID() string {
+	return randomID(8)
+}
+
+// NewSessionID genera un identificador aleatorio para una sesión nueva.
+func NewSessionID() string {
+	return randomID(16)
+}
+
+func randomID(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return hex.EncodeToString([]byte(time.Now().Format("150405.000000")))
+	}
+	return hex.EncodeToString(b)
+}
+
+// Millis convierte una duración a milisegundos.
+func Millis(d time.Duration) int64 {
+	return d.Milliseconds()
+}
@@ -0,0 +1,37 @@
+// Package builtin tiene las acciones incorporadas: acciones escritas en Go
+// que corren dentro del proceso, sin lanzar un ejecutable. Se habilitan con
+// BUILTIN_ACTIONS.
+package builtin
+
+import (
+	"fmt"
+
+	"github.com/ivanneira/Lapislazuli/internal/actions"
+)
+
+// Nombres de las acciones incorporadas.
+const (
+	TimeName    = "hora"
+	EchoName    = "eco"
+	WebhookName = "webhook"
+)
+
+// New crea la acción incorporada name. webhook configura la acción webhook.
+func New(name string, webhook WebhookConfig) (actions.Action, error) {
+	switch name {
+	case TimeName:
+		return &Time{}, nil
+	case EchoName:
+		return &Echo{}, nil
+	case WebhookName:
+		return NewWebhook(webhook)
+	}
+	return nil, fmt.Errorf("acción incorporada desconocida: %s", name)
+}
+
+// schema genera el JSON Schema de los parámetros, igual que para un
+// manifiesto.
+func schema(params ...actions.Parameter) map[string]interface{} {
+	m := actions.Manifest{Parameters: params}
+	return m.ParamsSchema()
+}
@@ -0,0 +1,34 @@
+package builtin
+
+import (
+	"context"
+
+	"github.com/ivanneira/Lapislazuli/internal/actions"
+	"github.com/ivanneira/Lapislazuli/pkg/actionsdk"
+)
+
+// Echo devuelve el texto que recibe. Sirve para probar la configuración sin
+// efectos secundarios.
+type Echo struct{}
+
+func (e *Echo) Name() string {
+	return EchoName
+}
+
+func (e *Echo) Describe() string {
+	return "Repite el texto que pide el usuario"
+}
+
+func (e *Echo) Schema() map[string]interface{} {
+	return schema(actions.Parameter{
+		Name:        "texto",
+		Type:        actions.TypeString,
+		Description: "texto a repetir",
+		Required:    true,
+	})
+}
+
+func (e *Echo) Execute(ctx context.Context, req actions.Request) (*actions.Result, error) {
+	text, _ := req.Params["texto"].(string)
+	return actionsdk.OK(text), nil
+}
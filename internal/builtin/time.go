@@ -0,0 +1,56 @@
+package builtin
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ivanneira/Lapislazuli/internal/actions"
+	"github.com/ivanneira/Lapislazuli/pkg/actionsdk"
+)
+
+// Time informa la fecha y la hora actuales, en la zona horaria pedida o en
+// la local. Now permite fijar el reloj; si es nil se usa time.Now.
+type Time struct {
+	Now func() time.Time
+}
+
+func (t *Time) Name() string {
+	return TimeName
+}
+
+func (t *Time) Describe() string {
+	return "Dice la fecha y la hora actuales"
+}
+
+func (t *Time) Schema() map[string]interface{} {
+	return schema(actions.Parameter{
+		Name:        "zona",
+		Type:        actions.TypeString,
+		Description: "zona horaria IANA, como America/Argentina/Buenos_Aires; por defecto la local",
+	})
+}
+
+func (t *Time) Execute(ctx context.Context, req actions.Request) (*actions.Result, error) {
+	loc := time.Local
+	if zone, _ := req.Params["zona"].(string); zone != "" {
+		var err error
+		if loc, err = time.LoadLocation(zone); err != nil {
+			return actionsdk.Errorf("invalid_timezone", "zona horaria desconocida: %s", zone), nil
+		}
+	}
+
+	now := time.Now
+	if t.Now != nil {
+		now = t.Now
+	}
+	current := now().In(loc)
+
+	resp := actionsdk.OK(fmt.Sprintf("Son las %s del %s (%s)",
+		current.Format("15:04"), current.Format("02/01/2006"), loc))
+	resp.Data = map[string]interface{}{
+		"time":     current.Format(time.RFC3339),
+		"timezone": loc.String(),
+	}
+	return resp, nil
+}
@@ -0,0 +1,108 @@
+package builtin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/ivanneira/Lapislazuli/internal/actions"
+	"github.com/ivanneira/Lapislazuli/pkg/actionsdk"
+)
+
+// maxWebhookResponse es el tamaño máximo que se lee de la respuesta del
+// webhook.
+const maxWebhookResponse = 1 << 20
+
+// defaultWebhookDescription es la descripción del webhook si no se configura
+// otra.
+const defaultWebhookDescription = "Envía el pedido del usuario a un servicio externo"
+
+// WebhookConfig configura la acción webhook. APIKey se envía como token
+// Bearer. Description le dice al modelo qué hace el servicio, por ejemplo
+// "Enciende o apaga las luces de la casa". Client es opcional.
+type WebhookConfig struct {
+	URL         string
+	APIKey      string
+	Description string
+	Client      *http.Client
+}
+
+// Webhook envía el Request de la acción, el mismo sobre que recibe un
+// ejecutable, como POST JSON a una URL fija. Si el servicio responde con un
+// Response del protocolo de acciones se usa tal cual; si no, el cuerpo es el
+// mensaje.
+type Webhook struct {
+	cfg WebhookConfig
+}
+
+// NewWebhook crea la acción webhook. Falla si no hay URL.
+func NewWebhook(cfg WebhookConfig) (*Webhook, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("la acción %s necesita WEBHOOK_URL", WebhookName)
+	}
+	if cfg.Description == "" {
+		cfg.Description = defaultWebhookDescription
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	return &Webhook{cfg: cfg}, nil
+}
+
+func (w *Webhook) Name() string {
+	return WebhookName
+}
+
+func (w *Webhook) Describe() string {
+	return w.cfg.Description
+}
+
+func (w *Webhook) Schema() map[string]interface{} {
+	return schema(actions.Parameter{
+		Name:        "mensaje",
+		Type:        actions.TypeString,
+		Description: "qué pide el usuario, en pocas palabras",
+	})
+}
+
+func (w *Webhook) Execute(ctx context.Context, req actions.Request) (*actions.Result, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, w.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if w.cfg.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+w.cfg.APIKey)
+	}
+
+	resp, err := w.cfg.Client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("Error al llamar al webhook: %v", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxWebhookResponse))
+	if err != nil {
+		return nil, fmt.Errorf("Error al leer la respuesta del webhook: %v", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return actionsdk.Errorf("webhook_error", "el webhook respondió %s", resp.Status), nil
+	}
+
+	var result actions.Result
+	if err := json.Unmarshal(data, &result); err == nil && result.Status != "" {
+		return &result, nil
+	}
+	message := strings.TrimSpace(string(data))
+	if message == "" {
+		message = "Pedido enviado"
+	}
+	return actionsdk.OK(message), nil
+}
@@ -0,0 +1,90 @@
+package coordinator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ivanneira/Lapislazuli/config"
+	"github.com/ivanneira/Lapislazuli/internal/mcp"
+	"github.com/ivanneira/Lapislazuli/internal/processor"
+)
+
+// AgentAction es la acción que informa el Result en modo agente.
+const AgentAction = "agent"
+
+// agentInstruction es la instrucción de sistema del agente.
+const agentInstruction = "Sos un asistente que puede ejecutar acciones llamando a las herramientas disponibles. " +
+	"Usalas cuando el usuario pida algo que resuelven; podés llamar a varias y usar el resultado de una en la siguiente. " +
+	"Si una herramienta devuelve un error, corregí la llamada o explicáselo al usuario. " +
+	"Cuando termines, respondé al usuario en el idioma %s contando lo que hiciste, sin inventar datos que no estén en los resultados."
+
+// handleAgent responde el prompt con el agente: cada acción configurada es
+// una herramienta que el modelo puede llamar. Las llamadas quedan en
+// Result.Calls y la respuesta final en Result.Reply; el transcript completo
+// queda en mctx.
+func handleAgent(ctx context.Context, mctx mcp.ModelContext, req Request) (*Result, error) {
+	res := &Result{Action: AgentAction, Calls: []StepResult{}}
+
+	start := time.Now()
+	answer, usage, err := processor.Agent(ctx, mctx, req.Prompt, fmt.Sprintf(agentInstruction, req.Locale), agentTools(),
+		func(ctx context.Context, call processor.ToolCall) string {
+			step := runTool(ctx, req, call)
+			res.Calls = append(res.Calls, step)
+			res.ExecutionTime += step.ExecutionTime
+			if step.Response != nil {
+				output, _ := json.Marshal(step.Response)
+				return string(output)
+			}
+			return "Error: " + step.Error
+		})
+	res.ReplyTime = time.Since(start) - res.ExecutionTime
+	res.Usage = usage
+	if len(res.Calls) > 0 {
+		res.Response = summarizePlan(res.Calls, err)
+	}
+	if err != nil {
+		return res, err
+	}
+	res.Reply = answer
+	emit(ctx, EventToken, map[string]string{"text": answer})
+	return res, nil
+}
+
+// agentTools expone cada acción configurada como herramienta, con el
+// esquema de sus parámetros.
+func agentTools() []processor.Tool {
+	tools := make([]processor.Tool, 0, len(config.Config.Manifests))
+	for i := range config.Config.Manifests {
+		m := &config.Config.Manifests[i]
+		tools = append(tools, processor.Tool{
+			Name:        m.Name,
+			Description: m.Description,
+			Parameters:  m.ParamsSchema(),
+		})
+	}
+	return tools
+}
+
+// runTool ejecuta la acción de una llamada del agente. Los errores quedan en
+// el StepResult: el agente se los pasa al modelo en lugar de cortar el turno.
+func runTool(ctx context.Context, req Request, call processor.ToolCall) StepResult {
+	step := StepResult{ID: call.ID, Action: call.Function.Name, Status: StepError}
+	if call.Function.Arguments != "" {
+		if err := json.Unmarshal([]byte(call.Function.Arguments), &step.Params); err != nil {
+			step.Error = fmt.Sprintf("argumentos inválidos para %s: %v", step.Action, err)
+			return step
+		}
+	}
+	if step.Params == nil {
+		step.Params = map[string]interface{}{}
+	}
+
+	emit(ctx, EventActionStarted, map[string]string{"action": step.Action, "step": step.ID})
+	runStep(ctx, req, &step)
+	if step.Response != nil {
+		emit(ctx, EventActionOutput, &step)
+	}
+	return step
+}
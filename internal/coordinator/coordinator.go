@@ -1,83 +1,227 @@
 package coordinator
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"os"
-	"os/exec"
-	"strings"
+	"time"
 
+	"github.com/ivanneira/Lapislazuli/config"
+	"github.com/ivanneira/Lapislazuli/internal/actions"
+	"github.com/ivanneira/Lapislazuli/internal/logger"
+	"github.com/ivanneira/Lapislazuli/internal/mcp"
 	"github.com/ivanneira/Lapislazuli/internal/processor"
+	"github.com/ivanneira/Lapislazuli/pkg/actionsdk"
 )
 
-// ClassificationResult define la estructura de la respuesta JSON.
-type ClassificationResult struct {
-	Action string `json:"action"`
+// ClassificationResult es la clasificación del prompt: la acción, sus
+// parámetros y las candidatas con su confianza.
+type ClassificationResult = processor.Classification
+
+// ExecutableResponse define la estructura de la respuesta JSON del ejecutable,
+// según el protocolo de acciones de actionsdk.
+type ExecutableResponse = actionsdk.Response
+
+// Request agrupa los datos de entrada de un prompt.
+type Request struct {
+	Prompt    string
+	SessionID string
+	Locale    string
 }
 
-// ExecutableResponse define la estructura de la respuesta JSON del ejecutable.
-type ExecutableResponse struct {
-	Message string `json:"message"`
-	Status  string `json:"status"`
+// Result agrupa el resultado de procesar un prompt: la acción clasificada,
+// la respuesta del ejecutable, lo que tardó cada etapa y los tokens usados.
+// Si la confianza no alcanza el umbral no se ejecuta nada: Clarification es
+// true y Reply tiene una pregunta para el usuario. En modo planificador Plan
+// tiene cada paso con su resultado; en modo agente Calls tiene cada
+// herramienta que llamó el modelo.
+type Result struct {
+	Action             string                 `json:"action"`
+	Params             map[string]interface{} `json:"params,omitempty"`
+	Confidence         float64                `json:"confidence"`
+	Candidates         []processor.Candidate  `json:"candidates,omitempty"`
+	Clarification      bool                   `json:"clarification,omitempty"`
+	Response           ExecutableResponse     `json:"response"`
+	Reply              string                 `json:"reply,omitempty"`
+	ClassificationTime time.Duration          `json:"classification_time"`
+	ExecutionTime      time.Duration          `json:"execution_time"`
+	ReplyTime          time.Duration          `json:"reply_time"`
+	Usage              processor.Usage        `json:"usage"`
+	Plan               []StepResult           `json:"plan,omitempty"`
+	Calls              []StepResult           `json:"calls,omitempty"`
 }
 
+// ActionError indica que la acción se ejecutó pero informó un error propio.
+type ActionError struct {
+	Action  string
+	Code    string
+	Message string
+}
+
+func (e *ActionError) Error() string {
+	return fmt.Sprintf("la acción %s falló (%s): %s", e.Action, e.Code, e.Message)
+}
+
+// InvalidParamsError indica que los parámetros que extrajo el modelo no
+// cumplen lo declarado en el manifiesto de la acción. Step es el paso del
+// plan, vacío fuera del modo planificador.
+type InvalidParamsError struct {
+	Action string
+	Step   string
+	Err    error
+}
+
+func (e *InvalidParamsError) Error() string {
+	if e.Step != "" {
+		return fmt.Sprintf("Parámetros inválidos para %s en el paso %s: %s", e.Action, e.Step, e.Err)
+	}
+	return fmt.Sprintf("Parámetros inválidos para %s: %s", e.Action, e.Err)
+}
+
+func (e *InvalidParamsError) Unwrap() error { return e.Err }
+
 // HandlePrompt recibe el prompt, llama al processor y ejecuta la acción clasificada.
-func HandlePrompt(prompt string) error {
-	// Llamar al modelo clasificador
-	resultJSON, err := processor.Process(prompt)
-	if err != nil {
-		return err
+func HandlePrompt(ctx context.Context, prompt string) (*Result, error) {
+	return Handle(ctx, Request{Prompt: prompt})
+}
+
+// Handle procesa un Request completo sin historial: clasifica, ejecuta la
+// acción y genera la respuesta al usuario. Si la clasificación termina, el
+// Result devuelto contiene la acción aunque la ejecución falle. La acción se
+// cancela si ctx se cancela.
+func Handle(ctx context.Context, req Request) (*Result, error) {
+	req = withDefaults(req)
+	if config.Config.Agent {
+		return handleAgent(ctx, mcp.NewContext(req.SessionID), req)
+	}
+	var res *Result
+	var err error
+	if config.Config.Planner {
+		res, err = handlePlan(ctx, req, func() (*processor.Plan, error) {
+			return processor.ProcessPlan(ctx, req.Prompt)
+		})
+	} else {
+		res, err = handle(ctx, req, func() (*ClassificationResult, error) {
+			return processor.Process(ctx, req.Prompt)
+		})
 	}
 
-	var result ClassificationResult
-	if err := json.Unmarshal([]byte(resultJSON), &result); err != nil {
-		return err
+	// La respuesta se genera sobre un contexto efímero con solo este turno
+	mctx := mcp.NewContext(req.SessionID)
+	mctx.AddMessage(mcp.RoleUser, req.Prompt)
+	finish(ctx, mctx, req, res, err)
+	return res, err
+}
+
+// HandleWithContext es como Handle pero clasifica el prompt con el historial
+// de la sesión guardado en mctx, donde además quedan registrados todos los
+// pasos.
+func HandleWithContext(ctx context.Context, mctx mcp.ModelContext, req Request) (*Result, error) {
+	if req.SessionID == "" {
+		req.SessionID = mctx.GetMetadata().SessionID
+	}
+	req = withDefaults(req)
+	if config.Config.Agent {
+		return handleAgent(ctx, mctx, req)
 	}
+	var res *Result
+	var err error
+	if config.Config.Planner {
+		res, err = handlePlan(ctx, req, func() (*processor.Plan, error) {
+			return processor.ProcessPlanWithContext(ctx, mctx, req.Prompt)
+		})
+	} else {
+		res, err = handle(ctx, req, func() (*ClassificationResult, error) {
+			return processor.ProcessWithContext(ctx, mctx, req.Prompt)
+		})
+	}
+	finish(ctx, mctx, req, res, err)
+	return res, err
+}
 
-	action := result.Action
-	fmt.Printf("Acción clasificada: %s\n", action)
+func withDefaults(req Request) Request {
+	if req.Locale == "" {
+		req.Locale = config.Config.Locale
+	}
+	return req
+}
+
+// handle clasifica con classify, valida la acción y la ejecuta. Si el
+// prompt no corresponde a ninguna acción o la confianza no alcanza
+// config.Config.ConfidenceThreshold, no ejecuta nada y marca el resultado
+// para pedir una aclaración.
+func handle(ctx context.Context, req Request, classify func() (*ClassificationResult, error)) (*Result, error) {
+	res := &Result{}
 
-	// Verificar si la acción está definida en el archivo .env
-	actions := os.Getenv("ACTIONS")
-	if !isValidAction(action, actions) {
-		return fmt.Errorf("Acción no definida: %s", action)
+	// Llamar al modelo clasificador
+	start := time.Now()
+	result, err := classify()
+	res.ClassificationTime = time.Since(start)
+	if err != nil {
+		return res, err
 	}
 
-	// Ejecutar el archivo correspondiente en la carpeta actions
-	actionPath := fmt.Sprintf("actions/%s.exe", action)
-	if _, err := os.Stat(actionPath); os.IsNotExist(err) {
-		return fmt.Errorf("Acción no definida: %s", action)
+	action := result.Action
+	res.Action = action
+	res.Confidence = result.Confidence
+	res.Candidates = result.Candidates
+	res.Usage = result.Usage
+	logger.Info("Acción clasificada: %s (confianza %.2f)", action, result.Confidence)
+	if action == actions.None || result.Confidence < config.Config.ConfidenceThreshold {
+		res.Clarification = true
+		emit(ctx, EventClassified, result)
+		return res, nil
 	}
 
-	// Capturar la salida del ejecutable
-	cmd := exec.Command(actionPath)
-	var outBuffer bytes.Buffer
-	cmd.Stdout = &outBuffer
-	cmd.Stderr = os.Stderr
+	// Verificar si la acción está declarada en los manifiestos
+	manifest, ok := actions.Find(config.Config.Manifests, action)
+	if !ok {
+		return res, fmt.Errorf("Acción no definida: %s", action)
+	}
 
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("Error al ejecutar la acción: %s", err)
+	// Validar los parámetros extraídos contra el manifiesto
+	params, err := manifest.ValidateParams(result.Params)
+	if err != nil {
+		return res, &InvalidParamsError{Action: action, Err: err}
 	}
+	res.Params = params
+	result.Params = params
+	emit(ctx, EventClassified, result)
 
-	// Leer y deserializar la salida del ejecutable
-	var execResponse ExecutableResponse
-	if err := json.Unmarshal(outBuffer.Bytes(), &execResponse); err != nil {
-		return fmt.Errorf("Error al deserializar la respuesta del ejecutable: %s", err)
+	// No lanzar la acción si la petición ya se canceló durante la clasificación
+	if err := ctx.Err(); err != nil {
+		return res, err
 	}
 
-	// Imprimir la respuesta del ejecutable en consola
-	fmt.Printf("Respuesta del ejecutable: %s (Estado: %s)\n", execResponse.Message, execResponse.Status)
+	emit(ctx, EventActionStarted, map[string]string{"action": action})
+	start = time.Now()
+	execResponse, err := runAction(ctx, manifest, actionsdk.Request{
+		Version:   actionsdk.ProtocolVersion,
+		Action:    action,
+		Prompt:    req.Prompt,
+		Params:    params,
+		SessionID: req.SessionID,
+		Locale:    req.Locale,
+	})
+	res.ExecutionTime = time.Since(start)
+	if err != nil {
+		return res, err
+	}
+	res.Response = *execResponse
+	emit(ctx, EventActionOutput, execResponse)
+	logger.Info("Respuesta de %s: %s (estado: %s)", action, execResponse.Message, execResponse.Status)
 
-	return nil
+	return res, actionError(action, execResponse)
 }
 
-// isValidAction verifica si la acción está en la lista de acciones permitidas.
-func isValidAction(action, actions string) bool {
-	for _, a := range strings.Split(actions, ",") {
-		if a == action {
-			return true
-		}
+// actionError devuelve un ActionError si la acción respondió con estado de
+// error, o nil si no.
+func actionError(action string, execResponse *ExecutableResponse) error {
+	if execResponse.Status != actionsdk.StatusError {
+		return nil
+	}
+	code := execResponse.ErrorCode
+	if code == "" {
+		code = "action_error"
 	}
-	return false
+	return &ActionError{Action: action, Code: code, Message: execResponse.Message}
 }
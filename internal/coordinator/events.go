@@ -0,0 +1,35 @@
+package coordinator
+
+import "context"
+
+// Eventos emitidos durante el procesamiento de un prompt.
+const (
+	EventClassified    = "classified"
+	EventActionStarted = "action_started"
+	EventActionOutput  = "action_output"
+	EventToken         = "token" // {"text": "..."}, para no perder espacios en SSE
+)
+
+// EventFunc recibe los eventos del procesamiento. Se llama de forma
+// sincrónica desde la goroutine que procesa el prompt.
+type EventFunc func(name string, data interface{})
+
+type eventsKey struct{}
+
+// WithEvents devuelve un contexto que hace que el coordinador emita eventos a fn.
+func WithEvents(ctx context.Context, fn EventFunc) context.Context {
+	return context.WithValue(ctx, eventsKey{}, fn)
+}
+
+// emit envía un evento si el contexto tiene un EventFunc.
+func emit(ctx context.Context, name string, data interface{}) {
+	if fn, ok := ctx.Value(eventsKey{}).(EventFunc); ok && fn != nil {
+		fn(name, data)
+	}
+}
+
+// streaming indica si el contexto pide eventos, y por lo tanto tokens de la respuesta.
+func streaming(ctx context.Context) bool {
+	fn, ok := ctx.Value(eventsKey{}).(EventFunc)
+	return ok && fn != nil
+}
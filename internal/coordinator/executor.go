@@ -0,0 +1,142 @@
+package coordinator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/ivanneira/Lapislazuli/config"
+	"github.com/ivanneira/Lapislazuli/internal/actions"
+	"github.com/ivanneira/Lapislazuli/internal/logger"
+	"github.com/ivanneira/Lapislazuli/pkg/actionsdk"
+)
+
+// waitDelay es cuánto se espera a que se cierren stdout/stderr después de
+// matar el proceso, por si algún hijo huérfano los mantiene abiertos.
+const waitDelay = 2 * time.Second
+
+// TimeoutError indica que la acción superó su tiempo máximo de ejecución.
+type TimeoutError struct {
+	Action  string
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("la acción %s superó el tiempo máximo de %s", e.Action, e.Timeout)
+}
+
+// actionTimeout devuelve el timeout de la acción, o ACTION_TIMEOUT si no
+// declara ninguno.
+func actionTimeout(manifest *actions.Manifest) time.Duration {
+	if timeout := manifest.TimeoutDuration(); timeout != 0 {
+		return timeout
+	}
+	return config.Config.ActionTimeout
+}
+
+// runAction ejecuta la implementación registrada de la acción, cortándola
+// si ctx se cancela o si vence el timeout de la acción.
+func runAction(ctx context.Context, manifest *actions.Manifest, req actionsdk.Request) (*ExecutableResponse, error) {
+	action, ok := registry.Get(manifest.Name)
+	if !ok {
+		return nil, fmt.Errorf("Acción no definida: %s", manifest.Name)
+	}
+
+	timeout := actionTimeout(manifest)
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	resp, err := action.Execute(ctx, req)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, &TimeoutError{Action: manifest.Name, Timeout: timeout}
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	return resp, nil
+}
+
+// executable es la implementación de una acción declarada en un manifiesto:
+// lanza el ejecutable, le escribe el Request por stdin y decodifica el
+// Response que devuelve por stdout. El proceso (y todo su grupo) se mata si
+// ctx se cancela.
+type executable struct {
+	manifest actions.Manifest
+}
+
+func (e *executable) Name() string {
+	return e.manifest.Name
+}
+
+func (e *executable) Describe() string {
+	return e.manifest.Description
+}
+
+func (e *executable) Schema() map[string]interface{} {
+	return e.manifest.ParamsSchema()
+}
+
+func (e *executable) Execute(ctx context.Context, req actionsdk.Request) (*ExecutableResponse, error) {
+	manifest := &e.manifest
+	actionPath := manifest.ExecPath()
+	if _, err := os.Stat(actionPath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("Acción no definida: %s", manifest.Name)
+	}
+
+	// Los parámetros viajan siempre en el sobre; además pueden pasarse como flags
+	args := manifest.Exec.Args
+	if manifest.Exec.ParamsAs == actions.ParamsFlags {
+		args = append(append([]string{}, args...), actions.Flags(req.Params)...)
+	}
+
+	stdin, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Los límites se aplican antes del exec, para que la acción nunca corra sin ellos
+	name := actionPath
+	if limits := manifest.Limits.Merge(config.Config.ActionLimits); !limits.IsZero() {
+		if name, args, err = limitCommand(actionPath, args, limits); err != nil {
+			logger.Warn("No se pudieron aplicar los límites a la acción %s: %v", manifest.Name, err)
+		}
+	}
+
+	// Capturar la salida del ejecutable
+	cmd := exec.CommandContext(ctx, name, args...)
+	var outBuffer bytes.Buffer
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &outBuffer
+	cmd.Stderr = os.Stderr
+	cmd.WaitDelay = waitDelay
+	configureProcess(cmd)
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("Error al ejecutar la acción: %s", err)
+	}
+
+	// Leer y deserializar la salida del ejecutable
+	var execResponse ExecutableResponse
+	if err := json.Unmarshal(outBuffer.Bytes(), &execResponse); err != nil {
+		return nil, fmt.Errorf("Error al deserializar la respuesta del ejecutable: %s", err)
+	}
+	if execResponse.Version != "" && execResponse.Version != actionsdk.ProtocolVersion {
+		logger.Warn("La acción %s responde con la versión de protocolo %s (se esperaba %s)",
+			manifest.Name, execResponse.Version, actionsdk.ProtocolVersion)
+	}
+	return &execResponse, nil
+}
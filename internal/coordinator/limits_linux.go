@@ -0,0 +1,33 @@
+//go:build linux
+
+package coordinator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ivanneira/Lapislazuli/internal/actions"
+)
+
+// limitShell es el shell que aplica los límites antes de lanzar la acción.
+const limitShell = "/bin/sh"
+
+// limitCommand devuelve el comando que lanza path con args bajo los límites:
+// un sh que fija los rlimits con ulimit (blando y duro) y se reemplaza por la
+// acción con exec, así la acción nunca corre sin ellos. Si un límite no se
+// puede fijar, la acción no se lanza.
+func limitCommand(path string, args []string, limits actions.Limits) (string, []string, error) {
+	var script strings.Builder
+	if limits.CPUSeconds > 0 {
+		fmt.Fprintf(&script, "ulimit -t %d && ", limits.CPUSeconds)
+	}
+	if limits.MemoryMB > 0 {
+		// ulimit -v es RLIMIT_AS en KiB
+		fmt.Fprintf(&script, "ulimit -v %d && ", limits.MemoryMB*1024)
+	}
+	if limits.OpenFiles > 0 {
+		fmt.Fprintf(&script, "ulimit -n %d && ", limits.OpenFiles)
+	}
+	script.WriteString(`exec "$0" "$@"`)
+	return limitShell, append([]string{"-c", script.String(), path}, args...), nil
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package coordinator
+
+import (
+	"errors"
+
+	"github.com/ivanneira/Lapislazuli/internal/actions"
+)
+
+// limitCommand no está soportado fuera de Linux: devuelve el comando sin
+// límites y un error.
+func limitCommand(path string, args []string, limits actions.Limits) (string, []string, error) {
+	return path, args, errors.New("los límites de recursos solo están soportados en Linux")
+}
@@ -0,0 +1,394 @@
+package coordinator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ivanneira/Lapislazuli/config"
+	"github.com/ivanneira/Lapislazuli/internal/actions"
+	"github.com/ivanneira/Lapislazuli/internal/logger"
+	"github.com/ivanneira/Lapislazuli/internal/processor"
+	"github.com/ivanneira/Lapislazuli/pkg/actionsdk"
+)
+
+// PlanAction es la acción que informa el Result cuando el plan tiene más de
+// un paso.
+const PlanAction = "plan"
+
+// Estados de un paso del plan.
+const (
+	StepOK          = "ok"
+	StepError       = "error"
+	StepSkipped     = "skipped"     // no se ejecutó porque falló un paso anterior
+	StepCompensated = "compensated" // se ejecutó y después se deshizo
+)
+
+// StepResult es un paso del plan con el resultado de ejecutarlo. Params son
+// los parámetros con las referencias a otros pasos ya resueltas.
+type StepResult struct {
+	ID            string                 `json:"id"`
+	Action        string                 `json:"action"`
+	Params        map[string]interface{} `json:"params,omitempty"`
+	DependsOn     []string               `json:"depends_on,omitempty"`
+	Status        string                 `json:"status"`
+	Response      *ExecutableResponse    `json:"response,omitempty"`
+	Compensation  *ExecutableResponse    `json:"compensation,omitempty"`
+	Error         string                 `json:"error,omitempty"`
+	ExecutionTime time.Duration          `json:"execution_time"`
+}
+
+// handlePlan es el equivalente de handle en modo planificador: arma el plan
+// con plan, valida sus pasos y los ejecuta respetando las dependencias. Con
+// un solo paso el Result queda igual que sin planificador; con varios la
+// acción es PlanAction y Response resume los mensajes de todos los pasos.
+func handlePlan(ctx context.Context, req Request, plan func() (*processor.Plan, error)) (*Result, error) {
+	res := &Result{Plan: []StepResult{}}
+
+	start := time.Now()
+	p, err := plan()
+	res.ClassificationTime = time.Since(start)
+	if err != nil {
+		return res, err
+	}
+
+	res.Confidence = p.Confidence
+	res.Usage = p.Usage
+	for _, step := range p.Steps {
+		res.Plan = append(res.Plan, StepResult{
+			ID:        step.ID,
+			Action:    step.Action,
+			Params:    step.Params,
+			DependsOn: step.DependsOn,
+			Status:    StepSkipped,
+		})
+	}
+	switch len(p.Steps) {
+	case 0:
+		res.Action = actions.None
+	case 1:
+		res.Action = p.Steps[0].Action
+		res.Params = p.Steps[0].Params
+	default:
+		res.Action = PlanAction
+	}
+	logger.Info("Plan clasificado: %d pasos (confianza %.2f)", len(p.Steps), p.Confidence)
+	if len(p.Steps) == 0 || p.Confidence < config.Config.ConfidenceThreshold {
+		res.Clarification = true
+		emit(ctx, EventClassified, p)
+		return res, nil
+	}
+
+	// Validar todo lo posible antes de ejecutar el primer paso
+	for i := range res.Plan {
+		step := &res.Plan[i]
+		manifest, ok := actions.Find(config.Config.Manifests, step.Action)
+		if !ok {
+			return res, fmt.Errorf("Acción no definida: %s", step.Action)
+		}
+		if hasReferences(step.Params) {
+			continue
+		}
+		params, err := manifest.ValidateParams(step.Params)
+		if err != nil {
+			return res, &InvalidParamsError{Action: step.Action, Step: step.ID, Err: err}
+		}
+		step.Params = params
+	}
+	if len(res.Plan) == 1 {
+		res.Params = res.Plan[0].Params
+	}
+	emit(ctx, EventClassified, p)
+
+	if err := ctx.Err(); err != nil {
+		return res, err
+	}
+
+	start = time.Now()
+	err = runPlan(ctx, req, res.Plan)
+	res.ExecutionTime = time.Since(start)
+
+	if len(res.Plan) == 1 {
+		if res.Plan[0].Response != nil {
+			res.Response = *res.Plan[0].Response
+		}
+		return res, err
+	}
+	res.Response = summarizePlan(res.Plan, err)
+	return res, err
+}
+
+// runPlan ejecuta los pasos por tandas: en cada una lanza en paralelo los
+// pasos cuyas dependencias ya terminaron bien. Si un paso falla no se lanzan
+// más y, con PLAN_ON_FAILURE=compensate, se deshacen los pasos terminados.
+// Devuelve el error del primer paso que falló.
+func runPlan(ctx context.Context, req Request, steps []StepResult) error {
+	done := map[string]*StepResult{}
+	var completed []int // en orden de terminación, para compensar al revés
+	pending := make([]int, len(steps))
+	for i := range steps {
+		pending[i] = i
+	}
+
+	var failure error
+	for len(pending) > 0 && failure == nil {
+		if err := ctx.Err(); err != nil {
+			failure = err
+			break
+		}
+
+		var ready, waiting []int
+		for _, i := range pending {
+			if dependenciesDone(steps[i].DependsOn, done) {
+				ready = append(ready, i)
+			} else {
+				waiting = append(waiting, i)
+			}
+		}
+		if len(ready) == 0 {
+			failure = fmt.Errorf("el plan no puede avanzar: quedan %d pasos con dependencias sin cumplir", len(waiting))
+			break
+		}
+
+		// Los eventos se emiten desde esta goroutine, nunca desde las de los pasos
+		errs := make([]error, len(steps))
+		var wg sync.WaitGroup
+		for _, i := range ready {
+			step := &steps[i]
+			params, err := resolveReferences(step.Params, done)
+			if err != nil {
+				step.Status = StepError
+				step.Error = err.Error()
+				errs[i] = err
+				continue
+			}
+			step.Params = params
+			emit(ctx, EventActionStarted, map[string]string{"action": step.Action, "step": step.ID})
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs[i] = runStep(ctx, req, step)
+			}()
+		}
+		wg.Wait()
+
+		for _, i := range ready {
+			step := &steps[i]
+			if step.Response != nil {
+				emit(ctx, EventActionOutput, step)
+			}
+			if errs[i] != nil {
+				if failure == nil {
+					failure = errs[i]
+				}
+				continue
+			}
+			done[step.ID] = step
+			completed = append(completed, i)
+		}
+		pending = waiting
+	}
+
+	if failure != nil && config.Config.PlanOnFailure == config.PlanCompensate {
+		compensate(ctx, req, steps, completed)
+	}
+	return failure
+}
+
+// runStep valida los parámetros del paso, ejecuta su acción y guarda el
+// resultado en step.
+func runStep(ctx context.Context, req Request, step *StepResult) error {
+	manifest, ok := actions.Find(config.Config.Manifests, step.Action)
+	if !ok {
+		err := fmt.Errorf("Acción no definida: %s", step.Action)
+		step.Status = StepError
+		step.Error = err.Error()
+		return err
+	}
+	params, err := manifest.ValidateParams(step.Params)
+	if err != nil {
+		err = &InvalidParamsError{Action: step.Action, Step: step.ID, Err: err}
+		step.Status = StepError
+		step.Error = err.Error()
+		return err
+	}
+	step.Params = params
+
+	start := time.Now()
+	execResponse, err := runAction(ctx, manifest, actionsdk.Request{
+		Version:   actionsdk.ProtocolVersion,
+		Action:    step.Action,
+		Prompt:    req.Prompt,
+		Params:    params,
+		SessionID: req.SessionID,
+		Locale:    req.Locale,
+	})
+	step.ExecutionTime = time.Since(start)
+	if err == nil {
+		step.Response = execResponse
+		err = actionError(step.Action, execResponse)
+	}
+	if err != nil {
+		step.Status = StepError
+		step.Error = err.Error()
+		return err
+	}
+	step.Status = StepOK
+	logger.Info("Paso %s (%s): %s", step.ID, step.Action, execResponse.Message)
+	return nil
+}
+
+// compensate ejecuta, en orden inverso, la acción de compensación de los
+// pasos terminados que la declaran. Se ejecutan aunque ctx se haya
+// cancelado: son justamente para dejar las cosas como estaban.
+func compensate(ctx context.Context, req Request, steps []StepResult, completed []int) {
+	ctx = context.WithoutCancel(ctx)
+	for j := len(completed) - 1; j >= 0; j-- {
+		step := &steps[completed[j]]
+		manifest, ok := actions.Find(config.Config.Manifests, step.Action)
+		if !ok || manifest.Compensate == "" {
+			continue
+		}
+		undo, ok := actions.Find(config.Config.Manifests, manifest.Compensate)
+		if !ok {
+			logger.Warn("La acción %s declara como compensación %s, que no está definida", step.Action, manifest.Compensate)
+			continue
+		}
+
+		logger.Info("Compensando el paso %s (%s) con %s", step.ID, step.Action, undo.Name)
+		execResponse, err := runAction(ctx, undo, actionsdk.Request{
+			Version:   actionsdk.ProtocolVersion,
+			Action:    undo.Name,
+			Prompt:    req.Prompt,
+			Params:    step.Params,
+			SessionID: req.SessionID,
+			Locale:    req.Locale,
+		})
+		if err == nil {
+			step.Compensation = execResponse
+			err = actionError(undo.Name, execResponse)
+		}
+		if err != nil {
+			logger.Warn("No se pudo compensar el paso %s: %v", step.ID, err)
+			step.Error = fmt.Sprintf("no se pudo compensar: %v", err)
+			continue
+		}
+		step.Status = StepCompensated
+	}
+}
+
+// dependenciesDone indica si todas las dependencias terminaron bien.
+func dependenciesDone(deps []string, done map[string]*StepResult) bool {
+	for _, dep := range deps {
+		if _, ok := done[dep]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// hasReferences indica si algún parámetro se refiere al resultado de otro paso.
+func hasReferences(params map[string]interface{}) bool {
+	for _, value := range params {
+		if s, ok := value.(string); ok && len(processor.StepReferences(s)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveReferences devuelve una copia de params con las referencias a otros
+// pasos reemplazadas por sus resultados. Un parámetro que es solo una
+// referencia toma el valor tal cual, con su tipo; si la referencia es parte
+// de un texto, se inserta como texto.
+func resolveReferences(params map[string]interface{}, done map[string]*StepResult) (map[string]interface{}, error) {
+	resolved := make(map[string]interface{}, len(params))
+	for name, value := range params {
+		s, ok := value.(string)
+		refs := processor.StepReferences(s)
+		if !ok || len(refs) == 0 {
+			resolved[name] = value
+			continue
+		}
+
+		if id, path, ok := processor.OnlyStepReference(s); ok {
+			v, err := stepValue(done, id, path)
+			if err != nil {
+				return nil, err
+			}
+			resolved[name] = v
+			continue
+		}
+
+		var refErr error
+		resolved[name] = processor.ReplaceStepReferences(s, func(id, path string) string {
+			v, err := stepValue(done, id, path)
+			if err != nil {
+				refErr = err
+				return ""
+			}
+			if text, ok := v.(string); ok {
+				return text
+			}
+			encoded, _ := json.Marshal(v)
+			return string(encoded)
+		})
+		if refErr != nil {
+			return nil, refErr
+		}
+	}
+	return resolved, nil
+}
+
+// stepValue busca path (por ejemplo "message" o "data.email") en la respuesta
+// del paso id.
+func stepValue(done map[string]*StepResult, id, path string) (interface{}, error) {
+	step, ok := done[id]
+	if !ok || step.Response == nil {
+		return nil, fmt.Errorf("el paso %s no terminó o no existe", id)
+	}
+	encoded, err := json.Marshal(step.Response)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	if err := json.Unmarshal(encoded, &value); err != nil {
+		return nil, err
+	}
+	for _, key := range strings.Split(path, ".") {
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("el resultado del paso %s no tiene %s", id, path)
+		}
+		if value, ok = fields[key]; !ok {
+			return nil, fmt.Errorf("el resultado del paso %s no tiene %s", id, path)
+		}
+	}
+	return value, nil
+}
+
+// summarizePlan arma la respuesta conjunta de un plan de varios pasos: el
+// mensaje de cada paso ejecutado y el estado de error si alguno falló.
+func summarizePlan(steps []StepResult, err error) ExecutableResponse {
+	var messages []string
+	for _, step := range steps {
+		switch {
+		case step.Response != nil:
+			messages = append(messages, fmt.Sprintf("%s (%s): %s", step.ID, step.Action, step.Response.Message))
+		case step.Error != "":
+			messages = append(messages, fmt.Sprintf("%s (%s): %s", step.ID, step.Action, step.Error))
+		}
+	}
+	status := actionsdk.StatusOK
+	if err != nil {
+		status = actionsdk.StatusError
+	}
+	return ExecutableResponse{
+		Version: actionsdk.ProtocolVersion,
+		Message: strings.Join(messages, "; "),
+		Status:  status,
+	}
+}
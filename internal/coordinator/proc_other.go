@@ -0,0 +1,9 @@
+//go:build !unix
+
+package coordinator
+
+import "os/exec"
+
+// configureProcess no hace nada fuera de Unix: al cancelar se mata solo el
+// proceso principal de la acción.
+func configureProcess(cmd *exec.Cmd) {}
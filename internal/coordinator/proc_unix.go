@@ -0,0 +1,17 @@
+//go:build unix
+
+package coordinator
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// configureProcess lanza la acción en su propio grupo de procesos para que,
+// al cancelarla, también mueran los hijos que haya creado.
+func configureProcess(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
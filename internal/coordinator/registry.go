@@ -0,0 +1,61 @@
+package coordinator
+
+import (
+	"context"
+
+	"github.com/ivanneira/Lapislazuli/config"
+	"github.com/ivanneira/Lapislazuli/internal/actions"
+	"github.com/ivanneira/Lapislazuli/internal/builtin"
+	"github.com/ivanneira/Lapislazuli/internal/logger"
+	"github.com/ivanneira/Lapislazuli/internal/mcpproto"
+)
+
+// registry tiene la implementación de cada acción configurada. Se arma en
+// LoadActions, antes de atender peticiones.
+var registry = actions.NewRegistry()
+
+// LoadActions registra la implementación de cada acción: el ejecutable de
+// cada manifiesto, las acciones incorporadas de BUILTIN_ACTIONS y las
+// herramientas de los servidores MCP externos, presentándose a ellos como
+// info. Las acciones que no tienen manifiesto se suman a
+// config.Config.Manifests. Devuelve una función que cierra las conexiones
+// con los servidores.
+func LoadActions(ctx context.Context, info mcpproto.Implementation) func() {
+	for _, m := range config.Config.Manifests {
+		if err := registry.Register(&executable{manifest: m}); err != nil {
+			logger.Warn("Se omite la acción %s: %v", m.Name, err)
+		}
+	}
+
+	var extra []actions.Action
+	webhook := builtin.WebhookConfig{
+		URL:         config.Config.WebhookURL,
+		APIKey:      config.Config.WebhookAPIKey,
+		Description: config.Config.WebhookDescription,
+	}
+	for _, name := range config.Config.BuiltinActions {
+		a, err := builtin.New(name, webhook)
+		if err != nil {
+			logger.Warn("Se omite la acción incorporada %s: %v", name, err)
+			continue
+		}
+		extra = append(extra, a)
+	}
+	extra = append(extra, connectServers(ctx, info)...)
+
+	manifests := make([]actions.Manifest, 0, len(extra))
+	for _, a := range extra {
+		m, err := actions.FromAction(a)
+		if err == nil {
+			err = registry.Register(a)
+		}
+		if err != nil {
+			logger.Warn("Se omite la acción %s: %v", a.Name(), err)
+			continue
+		}
+		manifests = append(manifests, m)
+	}
+	config.AddManifests(manifests)
+
+	return closeServers
+}
@@ -0,0 +1,149 @@
+package coordinator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ivanneira/Lapislazuli/config"
+	"github.com/ivanneira/Lapislazuli/internal/actions"
+	"github.com/ivanneira/Lapislazuli/internal/logger"
+	"github.com/ivanneira/Lapislazuli/internal/mcpproto"
+	"github.com/ivanneira/Lapislazuli/pkg/actionsdk"
+)
+
+// remoteErrorCode es el error_code de la respuesta cuando una herramienta de
+// un servidor MCP externo informa que falló.
+const remoteErrorCode = "tool_error"
+
+// remoteClients son los clientes de los servidores MCP externos, por nombre.
+// Se arma en LoadActions, antes de atender peticiones, y después solo se lee.
+var remoteClients = map[string]*mcpproto.Client{}
+
+// remoteTool es la implementación de una acción que es una herramienta de un
+// servidor MCP externo: se ejecuta con tools/call y el resultado se
+// convierte en la respuesta de un ejecutable.
+type remoteTool struct {
+	name   string
+	tool   mcpproto.Tool
+	client *mcpproto.Client
+}
+
+func (r *remoteTool) Name() string {
+	return r.name
+}
+
+func (r *remoteTool) Describe() string {
+	return r.tool.Description
+}
+
+func (r *remoteTool) Schema() map[string]interface{} {
+	return r.tool.InputSchema
+}
+
+func (r *remoteTool) Execute(ctx context.Context, req actionsdk.Request) (*ExecutableResponse, error) {
+	result, err := r.client.CallTool(ctx, r.tool.Name, req.Params)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("Error al ejecutar la acción: %s", err)
+	}
+
+	texts := make([]string, 0, len(result.Content))
+	for _, content := range result.Content {
+		if content.Type == "text" && content.Text != "" {
+			texts = append(texts, content.Text)
+		}
+	}
+	resp := &ExecutableResponse{
+		Version: actionsdk.ProtocolVersion,
+		Message: strings.Join(texts, "\n"),
+		Status:  actionsdk.StatusOK,
+		Data:    result.StructuredContent,
+	}
+	if result.IsError {
+		resp.Status = actionsdk.StatusError
+		resp.ErrorCode = remoteErrorCode
+	}
+	return resp, nil
+}
+
+// connectServers se conecta a los servidores MCP externos configurados,
+// presentándose como info, y devuelve sus herramientas como acciones. Los
+// servidores que no responden se informan y se omiten.
+func connectServers(ctx context.Context, info mcpproto.Implementation) []actions.Action {
+	var tools []actions.Action
+	for _, server := range config.Config.MCPServers {
+		client, list, err := connectServer(ctx, server, info)
+		if err != nil {
+			logger.Error("No se pudo conectar con el servidor MCP %s: %v", server.Name, err)
+			continue
+		}
+		remoteClients[server.Name] = client
+		logger.Info("Servidor MCP %s: %d herramientas", server.Name, len(list))
+		for _, tool := range list {
+			tools = append(tools, &remoteTool{name: server.Prefix + tool.Name, tool: tool, client: client})
+		}
+	}
+	return tools
+}
+
+// closeServers cierra las conexiones con los servidores MCP externos y
+// termina sus procesos.
+func closeServers() {
+	for name, client := range remoteClients {
+		if err := client.Close(); err != nil {
+			logger.Warn("Error cerrando el servidor MCP %s: %v", name, err)
+		}
+	}
+}
+
+// connectServer hace el handshake con el servidor y devuelve sus
+// herramientas, o solo las de server.Tools si no está vacío.
+func connectServer(ctx context.Context, server config.MCPServer, info mcpproto.Implementation) (*mcpproto.Client, []mcpproto.Tool, error) {
+	var client *mcpproto.Client
+	if server.URL != "" {
+		headers := map[string]string{}
+		if server.APIKey != "" {
+			headers["Authorization"] = "Bearer " + server.APIKey
+		}
+		client = mcpproto.NewHTTPClient(server.URL, headers)
+	} else {
+		var err error
+		client, err = mcpproto.NewStdioClient(server.Command[0], server.Command[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if timeout := config.Config.ActionTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if _, err := client.Initialize(ctx, info); err != nil {
+		client.Close()
+		return nil, nil, err
+	}
+	tools, err := client.ListTools(ctx)
+	if err != nil {
+		client.Close()
+		return nil, nil, err
+	}
+	if len(server.Tools) == 0 {
+		return client, tools, nil
+	}
+
+	enabled := make(map[string]bool, len(server.Tools))
+	for _, name := range server.Tools {
+		enabled[name] = true
+	}
+	selected := make([]mcpproto.Tool, 0, len(server.Tools))
+	for _, tool := range tools {
+		if enabled[tool.Name] {
+			selected = append(selected, tool)
+		}
+	}
+	return client, selected, nil
+}
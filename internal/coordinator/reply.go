@@ -0,0 +1,217 @@
+package coordinator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ivanneira/Lapislazuli/config"
+	"github.com/ivanneira/Lapislazuli/internal/actions"
+	"github.com/ivanneira/Lapislazuli/internal/logger"
+	"github.com/ivanneira/Lapislazuli/internal/mcp"
+	"github.com/ivanneira/Lapislazuli/internal/processor"
+)
+
+// replyInstruction es la instrucción de sistema para el modelo de respuestas.
+const replyInstruction = "Respondé al usuario en una o dos oraciones, en el idioma %s, " +
+	"contando el resultado de la última acción ejecutada. No inventes datos que no estén en el resultado."
+
+// planReplyInstruction es la instrucción de sistema para responder después de
+// ejecutar un plan de varios pasos.
+const planReplyInstruction = "Respondé al usuario en una a tres oraciones, en el idioma %s, " +
+	"contando el resultado de cada paso del plan ejecutado, incluidos los que fallaron o no se ejecutaron. No inventes datos que no estén en los resultados."
+
+// clarifyInstruction es la instrucción de sistema para pedir una aclaración
+// cuando la clasificación no es confiable.
+const clarifyInstruction = "No está claro qué quiere hacer el usuario. Hacé una sola pregunta breve, " +
+	"en el idioma %s, para que aclare qué quiere. No ejecutes ni prometas ninguna acción.%s"
+
+// ReplyData son los datos disponibles en las plantillas de respuesta.
+type ReplyData struct {
+	Prompt   string
+	Action   string
+	Params   map[string]interface{}
+	Response ExecutableResponse
+	Error    string
+}
+
+// finish registra en mctx la clasificación y el resultado de la acción y
+// completa res.Reply según el modo de respuesta de la acción. Un fallo al
+// generar la respuesta no es un error del prompt: se informa y se omite.
+func finish(ctx context.Context, mctx mcp.ModelContext, req Request, res *Result, err error) {
+	if res.Action == "" {
+		return
+	}
+	recordSteps(mctx, res, err)
+	if ctx.Err() != nil {
+		// Nadie va a leer la respuesta
+		return
+	}
+
+	if res.Clarification {
+		start := time.Now()
+		question, usage, clarifyErr := clarify(ctx, mctx, req, res)
+		res.ReplyTime = time.Since(start)
+		res.Usage = res.Usage.Add(usage)
+		if clarifyErr != nil {
+			logger.Warn("No se pudo generar la pregunta de aclaración: %v", clarifyErr)
+			return
+		}
+		res.Reply = question
+		return
+	}
+
+	mode := config.Config.ReplyMode
+	manifest, ok := actions.Find(config.Config.Manifests, res.Action)
+	if ok && manifest.Reply.Mode != "" {
+		mode = manifest.Reply.Mode
+	}
+	if res.Action == PlanAction && mode == actions.ReplyTemplate {
+		// Las plantillas son de cada acción; un plan se cuenta con el modelo
+		mode = actions.ReplyLLM
+	}
+
+	start := time.Now()
+	reply, usage, replyErr := reply(ctx, mctx, mode, manifest, req, res, err)
+	res.ReplyTime = time.Since(start)
+	res.Usage = res.Usage.Add(usage)
+	if replyErr != nil {
+		logger.Warn("No se pudo generar la respuesta: %v", replyErr)
+		return
+	}
+	res.Reply = reply
+}
+
+// clarify genera con el modelo de respuestas una pregunta para aclarar la
+// intención, mencionando las acciones candidatas.
+func clarify(ctx context.Context, mctx mcp.ModelContext, req Request, res *Result) (string, processor.Usage, error) {
+	var options strings.Builder
+	for _, c := range res.Candidates {
+		manifest, ok := actions.Find(config.Config.Manifests, c.Action)
+		if !ok {
+			continue
+		}
+		if options.Len() == 0 {
+			options.WriteString(" Acciones posibles:")
+		}
+		options.WriteString(" ")
+		options.WriteString(manifest.Name)
+		if manifest.Description != "" {
+			options.WriteString(" (" + manifest.Description + ")")
+		}
+		options.WriteString(";")
+	}
+
+	instruction := fmt.Sprintf(clarifyInstruction, req.Locale, strings.TrimSuffix(options.String(), ";"))
+	if streaming(ctx) {
+		return processor.StreamReply(ctx, mctx, instruction, func(token string) {
+			emit(ctx, EventToken, map[string]string{"text": token})
+		})
+	}
+	return processor.Reply(ctx, mctx, instruction)
+}
+
+// reply genera la respuesta en el modo indicado. Si ctx pide eventos, la
+// respuesta del modelo se emite token a token. Las respuestas de plantilla y
+// crudas se agregan al contexto igual que las del modelo.
+func reply(ctx context.Context, mctx mcp.ModelContext, mode string, manifest *actions.Manifest, req Request, res *Result, err error) (string, processor.Usage, error) {
+	var text string
+	switch mode {
+	case actions.ReplyLLM:
+		instruction := fmt.Sprintf(replyInstruction, req.Locale)
+		if res.Action == PlanAction {
+			instruction = fmt.Sprintf(planReplyInstruction, req.Locale)
+		}
+		if streaming(ctx) {
+			return processor.StreamReply(ctx, mctx, instruction, func(token string) {
+				emit(ctx, EventToken, map[string]string{"text": token})
+			})
+		}
+		return processor.Reply(ctx, mctx, instruction)
+	case actions.ReplyTemplate:
+		if manifest == nil {
+			return "", processor.Usage{}, fmt.Errorf("la acción %s no tiene plantilla de respuesta", res.Action)
+		}
+		data := ReplyData{
+			Prompt:   req.Prompt,
+			Action:   res.Action,
+			Params:   res.Params,
+			Response: res.Response,
+		}
+		if err != nil {
+			data.Error = err.Error()
+		}
+		rendered, renderErr := manifest.RenderReply(data)
+		if renderErr != nil {
+			return "", processor.Usage{}, renderErr
+		}
+		text = rendered
+	case actions.ReplyRaw:
+		text = res.Response.Message
+		if err != nil {
+			text = err.Error()
+		}
+	default:
+		return "", processor.Usage{}, fmt.Errorf("modo de respuesta desconocido: %s", mode)
+	}
+
+	mctx.AddMessage(mcp.RoleAssistant, text)
+	emit(ctx, EventToken, map[string]string{"text": text})
+	return text, processor.Usage{}, nil
+}
+
+// recordSteps agrega al contexto la clasificación elegida y el resultado (o
+// el error) de la acción. El prompt del usuario ya lo agrega el clasificador.
+func recordSteps(mctx mcp.ModelContext, res *Result, err error) {
+	if res.Plan != nil {
+		recordPlan(mctx, res)
+		return
+	}
+	classification, _ := json.Marshal(ClassificationResult{
+		Action:     res.Action,
+		Params:     res.Params,
+		Confidence: res.Confidence,
+	})
+	mctx.AddMessage(mcp.RoleAssistant, string(classification))
+	if res.Clarification {
+		return
+	}
+
+	if err != nil {
+		mctx.AddMessage(mcp.RoleAction, fmt.Sprintf("Error en la acción %s: %v", res.Action, err))
+		return
+	}
+	output, _ := json.Marshal(res.Response)
+	mctx.AddMessage(mcp.RoleAction, fmt.Sprintf("Resultado de la acción %s: %s", res.Action, output))
+}
+
+// recordPlan agrega al contexto el plan elegido y el resultado de cada paso.
+func recordPlan(mctx mcp.ModelContext, res *Result) {
+	steps := make([]processor.Step, len(res.Plan))
+	for i, step := range res.Plan {
+		steps[i] = processor.Step{ID: step.ID, Action: step.Action, Params: step.Params, DependsOn: step.DependsOn}
+	}
+	plan, _ := json.Marshal(processor.Plan{Steps: steps, Confidence: res.Confidence})
+	mctx.AddMessage(mcp.RoleAssistant, string(plan))
+	if res.Clarification {
+		return
+	}
+
+	for _, step := range res.Plan {
+		switch {
+		case step.Status == StepSkipped:
+			mctx.AddMessage(mcp.RoleAction, fmt.Sprintf("El paso %s (%s) no se ejecutó", step.ID, step.Action))
+		case step.Response == nil:
+			mctx.AddMessage(mcp.RoleAction, fmt.Sprintf("Error en el paso %s (%s): %s", step.ID, step.Action, step.Error))
+		default:
+			output, _ := json.Marshal(step.Response)
+			text := fmt.Sprintf("Resultado del paso %s (%s): %s", step.ID, step.Action, output)
+			if step.Status == StepCompensated {
+				text += " (deshecho después)"
+			}
+			mctx.AddMessage(mcp.RoleAction, text)
+		}
+	}
+}
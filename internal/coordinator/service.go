@@ -0,0 +1,60 @@
+package coordinator
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/ivanneira/Lapislazuli/internal/mcp"
+)
+
+// Propiedades del contexto de sesión que usa el servicio.
+const (
+	PropertyLocale = "locale"
+)
+
+// Service implementa mcp.AIService sobre el coordinador: clasifica el prompt
+// con el historial de la sesión, ejecuta la acción elegida y genera la
+// respuesta al usuario. Cada paso queda registrado como mensaje en el
+// contexto de la sesión, que se persiste con el SessionManager.
+type Service struct {
+	sessions *mcp.SessionManager
+}
+
+// NewService crea el servicio del coordinador sobre sessions.
+func NewService(sessions *mcp.SessionManager) *Service {
+	return &Service{sessions: sessions}
+}
+
+// Process procesa el prompt y devuelve el Result serializado en JSON. Si hubo
+// un error después de clasificar, se devuelve igualmente el Result parcial
+// junto con el error.
+func (s *Service) Process(ctx context.Context, mctx mcp.ModelContext, input string) (string, error) {
+	req := Request{
+		Prompt:    input,
+		SessionID: mctx.GetMetadata().SessionID,
+	}
+	if locale, ok := mctx.GetProperty(PropertyLocale).(string); ok {
+		req.Locale = locale
+	}
+
+	res, err := HandleWithContext(ctx, mctx, req)
+	if res == nil {
+		return "", err
+	}
+
+	output, jsonErr := json.Marshal(res)
+	if jsonErr != nil {
+		return "", jsonErr
+	}
+	return string(output), err
+}
+
+// LoadContext devuelve el contexto de la sesión, o uno nuevo si no existe o venció.
+func (s *Service) LoadContext(sessionID string) (mcp.ModelContext, error) {
+	return s.sessions.GetContext(sessionID)
+}
+
+// SaveContext persiste el contexto de la sesión.
+func (s *Service) SaveContext(ctx mcp.ModelContext) error {
+	return s.sessions.SaveContext(ctx)
+}
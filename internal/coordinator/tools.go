@@ -0,0 +1,63 @@
+package coordinator
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/ivanneira/Lapislazuli/config"
+	"github.com/ivanneira/Lapislazuli/internal/actions"
+	"github.com/ivanneira/Lapislazuli/internal/mcpproto"
+)
+
+// Tools expone las acciones configuradas como herramientas MCP. Cada
+// llamada ejecuta la acción directamente, sin clasificar ni generar
+// respuesta: de eso se encarga el agente que la llama.
+type Tools struct{}
+
+// NewTools crea el proveedor de herramientas del servidor MCP.
+func NewTools() *Tools {
+	return &Tools{}
+}
+
+// ListTools devuelve una herramienta por acción, con el esquema de sus
+// parámetros.
+func (t *Tools) ListTools(ctx context.Context) []mcpproto.Tool {
+	tools := make([]mcpproto.Tool, 0, len(config.Config.Manifests))
+	for _, tool := range agentTools() {
+		tools = append(tools, mcpproto.Tool{
+			Name:        tool.Name,
+			Description: tool.Description,
+			InputSchema: tool.Parameters,
+		})
+	}
+	return tools
+}
+
+// CallTool ejecuta la acción name con los argumentos como parámetros. El
+// mensaje de la acción va como texto y su respuesta completa como contenido
+// estructurado; si la acción falla o los parámetros no son válidos, el
+// resultado se marca como error.
+func (t *Tools) CallTool(ctx context.Context, name string, args map[string]interface{}) (*mcpproto.CallToolResult, error) {
+	if _, ok := actions.Find(config.Config.Manifests, name); !ok {
+		return nil, mcpproto.ErrUnknownTool
+	}
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+
+	step := StepResult{ID: name, Action: name, Params: args, Status: StepError}
+	err := runStep(ctx, withDefaults(Request{}), &step)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if step.Response == nil {
+		return mcpproto.ErrorResult(step.Error), nil
+	}
+
+	result := mcpproto.TextResult(step.Response.Message)
+	result.IsError = err != nil
+	if encoded, jsonErr := json.Marshal(step.Response); jsonErr == nil {
+		json.Unmarshal(encoded, &result.StructuredContent)
+	}
+	return result, nil
+}
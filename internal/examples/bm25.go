@@ -0,0 +1,105 @@
+package examples
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Parámetros habituales de BM25.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// bm25 es un índice BM25 en memoria sobre textos cortos.
+type bm25 struct {
+	docs   []map[string]int
+	length []int
+	avgLen float64
+	df     map[string]int
+}
+
+func newBM25(texts []string) *bm25 {
+	idx := &bm25{
+		docs:   make([]map[string]int, len(texts)),
+		length: make([]int, len(texts)),
+		df:     map[string]int{},
+	}
+	total := 0
+	for i, text := range texts {
+		terms := tokenize(text)
+		freq := make(map[string]int, len(terms))
+		for _, t := range terms {
+			freq[t]++
+		}
+		for t := range freq {
+			idx.df[t]++
+		}
+		idx.docs[i] = freq
+		idx.length[i] = len(terms)
+		total += len(terms)
+	}
+	if len(texts) > 0 {
+		idx.avgLen = float64(total) / float64(len(texts))
+	}
+	return idx
+}
+
+// search devuelve los índices de los k documentos con mayor puntaje para
+// query, de mayor a menor.
+func (idx *bm25) search(query string, k int) []int {
+	type scored struct {
+		doc   int
+		score float64
+	}
+	n := float64(len(idx.docs))
+	terms := tokenize(query)
+
+	var results []scored
+	for doc, freq := range idx.docs {
+		score := 0.0
+		for _, t := range terms {
+			tf := float64(freq[t])
+			if tf == 0 {
+				continue
+			}
+			df := float64(idx.df[t])
+			idf := math.Log((n-df+0.5)/(df+0.5) + 1)
+			norm := 1 - bm25B + bm25B*float64(idx.length[doc])/idx.avgLen
+			score += idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+		}
+		if score > 0 {
+			results = append(results, scored{doc: doc, score: score})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].score > results[j].score
+	})
+	if k > 0 && len(results) > k {
+		results = results[:k]
+	}
+	docs := make([]int, len(results))
+	for i, r := range results {
+		docs[i] = r.doc
+	}
+	return docs
+}
+
+// tokenize pasa el texto a minúsculas, le quita los acentos y lo separa en
+// palabras.
+func tokenize(text string) []string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	if plain, _, err := transform.String(t, text); err == nil {
+		text = plain
+	}
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,117 @@
+// Package examples guarda frases de ejemplo por acción, que se usan como
+// ejemplos few-shot para el clasificador.
+package examples
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ivanneira/Lapislazuli/internal/actions"
+	"github.com/ivanneira/Lapislazuli/internal/logger"
+)
+
+// Example es una frase del usuario con la clasificación esperada.
+type Example struct {
+	Action string                 `json:"action"`
+	Text   string                 `json:"text"`
+	Params map[string]interface{} `json:"params,omitempty"`
+}
+
+// Store es un conjunto de ejemplos indexado para buscar los más parecidos a
+// un prompt.
+type Store struct {
+	examples []Example
+	index    *bm25
+}
+
+// NewStore crea un Store con los ejemplos dados.
+func NewStore(examples []Example) *Store {
+	docs := make([]string, len(examples))
+	for i, e := range examples {
+		docs[i] = e.Text
+	}
+	return &Store{examples: examples, index: newBM25(docs)}
+}
+
+// Load arma el Store con los ejemplos de los manifiestos y, si path no está
+// vacío, los del archivo JSONL indicado (un Example por línea). Los ejemplos
+// de acciones que no están en manifests se descartan, salvo los de
+// actions.None.
+func Load(manifests []actions.Manifest, path string) (*Store, error) {
+	var list []Example
+	for _, m := range manifests {
+		for _, text := range m.Examples {
+			list = append(list, Example{Action: m.Name, Text: text})
+		}
+	}
+
+	if path != "" {
+		fromFile, err := LoadFile(path)
+		if err != nil {
+			return NewStore(list), err
+		}
+		for _, e := range fromFile {
+			if _, ok := actions.Find(manifests, e.Action); !ok && e.Action != actions.None {
+				logger.Warn("Se ignora el ejemplo %q: la acción %s no está habilitada", e.Text, e.Action)
+				continue
+			}
+			list = append(list, e)
+		}
+	}
+	return NewStore(list), nil
+}
+
+// LoadFile lee un archivo JSONL de ejemplos. Las líneas vacías y las que
+// empiezan con # se ignoran.
+func LoadFile(path string) ([]Example, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var list []Example
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var e Example
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		if e.Action == "" || e.Text == "" {
+			return nil, fmt.Errorf("%s:%d: el ejemplo necesita action y text", path, n)
+		}
+		list = append(list, e)
+	}
+	return list, scanner.Err()
+}
+
+// All devuelve todos los ejemplos.
+func (s *Store) All() []Example {
+	return s.examples
+}
+
+// Len devuelve la cantidad de ejemplos.
+func (s *Store) Len() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.examples)
+}
+
+// Similar devuelve hasta k ejemplos ordenados por similitud BM25 con prompt.
+// Los ejemplos sin ninguna palabra en común no se devuelven.
+func (s *Store) Similar(prompt string, k int) []Example {
+	ranked := s.index.search(prompt, k)
+	result := make([]Example, len(ranked))
+	for i, doc := range ranked {
+		result[i] = s.examples[doc]
+	}
+	return result
+}
@@ -0,0 +1,149 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ivanneira/Lapislazuli/internal/logger"
+)
+
+// ErrCircuitOpen indica que el circuito del backend está abierto y la llamada
+// no se intentó.
+var ErrCircuitOpen = errors.New("circuito abierto: el servidor del modelo falló repetidamente")
+
+// Estados del circuito.
+const (
+	circuitClosed   = iota // las llamadas pasan
+	circuitOpen            // las llamadas fallan sin intentarse
+	circuitHalfOpen        // pasa una llamada de prueba
+)
+
+// Breaker es un circuit breaker: después de Threshold fallos transitorios
+// seguidos rechaza las llamadas durante Cooldown y luego deja pasar una de
+// prueba; si funciona vuelve a cerrarse.
+type Breaker struct {
+	Threshold int
+	Cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    int
+	failures int
+	openedAt time.Time
+	now      func() time.Time
+}
+
+// NewBreaker crea un Breaker. Un threshold menor que 1 lo deshabilita.
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{Threshold: threshold, Cooldown: cooldown, now: time.Now}
+}
+
+// allow indica si se puede intentar una llamada.
+func (b *Breaker) allow() bool {
+	if b.Threshold < 1 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case circuitOpen:
+		if b.now().Sub(b.openedAt) < b.Cooldown {
+			return false
+		}
+		b.state = circuitHalfOpen
+		return true
+	case circuitHalfOpen:
+		// Ya hay una llamada de prueba en curso
+		return false
+	default:
+		return true
+	}
+}
+
+// record registra el resultado de una llamada.
+func (b *Breaker) record(err error) {
+	if b.Threshold < 1 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if errors.Is(err, context.Canceled) {
+		// La cancelación no dice nada del servidor: si era la llamada de
+		// prueba, la próxima vuelve a probar
+		if b.state == circuitHalfOpen {
+			b.state = circuitOpen
+		}
+		return
+	}
+	if !Retryable(err) {
+		if b.state != circuitClosed {
+			logger.Info("Circuito cerrado: el servidor del modelo volvió a responder")
+		}
+		b.state = circuitClosed
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == circuitHalfOpen || b.failures >= b.Threshold {
+		if b.state != circuitOpen {
+			logger.Warn("Circuito abierto por %v tras %d fallos: %v", b.Cooldown, b.failures, err)
+		}
+		b.state = circuitOpen
+		b.openedAt = b.now()
+	}
+}
+
+// call ejecuta fn si el circuito lo permite y registra su resultado.
+func (b *Breaker) call(fn func() error) error {
+	if !b.allow() {
+		return ErrCircuitOpen
+	}
+	err := fn()
+	b.record(err)
+	return err
+}
+
+// breaking protege otro Backend con un Breaker.
+type breaking struct {
+	backend Backend
+	breaker *Breaker
+}
+
+// WithBreaker envuelve backend con breaker. Cada intento cuenta, así que
+// conviene aplicarlo por debajo de WithRetry.
+func WithBreaker(backend Backend, breaker *Breaker) Backend {
+	return &breaking{backend: backend, breaker: breaker}
+}
+
+func (b *breaking) Chat(ctx context.Context, req ChatRequest) (resp *ChatResponse, err error) {
+	err = b.breaker.call(func() error {
+		resp, err = b.backend.Chat(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+func (b *breaking) Structured(ctx context.Context, req ChatRequest, schema Schema) (resp *ChatResponse, err error) {
+	err = b.breaker.call(func() error {
+		resp, err = b.backend.Structured(ctx, req, schema)
+		return err
+	})
+	return resp, err
+}
+
+func (b *breaking) Stream(ctx context.Context, req ChatRequest, onToken func(string)) (resp *ChatResponse, err error) {
+	err = b.breaker.call(func() error {
+		resp, err = b.backend.Stream(ctx, req, onToken)
+		return err
+	})
+	return resp, err
+}
+
+func (b *breaking) Embed(ctx context.Context, model string, input []string) (embeddings [][]float32, err error) {
+	err = b.breaker.call(func() error {
+		embeddings, err = b.backend.Embed(ctx, model, input)
+		return err
+	})
+	return embeddings, err
+}
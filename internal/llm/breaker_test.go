@@ -0,0 +1,116 @@
+package llm
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// fakeClock es un reloj que solo avanza a mano.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func newTestBreaker(threshold int) (*Breaker, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	b := NewBreaker(threshold, time.Minute)
+	b.now = clock.Now
+	return b, clock
+}
+
+func TestBreakerOpensAndHalfOpens(t *testing.T) {
+	server := newFlakyServer(t,
+		status(http.StatusInternalServerError, ""),
+		status(http.StatusInternalServerError, ""),
+		reply(okChat),
+	)
+	breaker, clock := newTestBreaker(2)
+	backend := WithBreaker(NewOpenAI(Config{URL: server.URL}), breaker)
+
+	for i := 0; i < 2; i++ {
+		if _, err := chat(backend); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("llamada %d: se esperaba el error del servidor, llegó %v", i+1, err)
+		}
+	}
+	if _, err := chat(backend); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("se esperaba el circuito abierto, llegó %v", err)
+	}
+	if got := server.calls.Load(); got != 2 {
+		t.Fatalf("con el circuito abierto no debería llamarse al servidor: %d llamadas", got)
+	}
+
+	// Pasado el cooldown pasa una llamada de prueba y, si funciona, se cierra
+	clock.now = clock.now.Add(time.Minute)
+	if _, err := chat(backend); err != nil {
+		t.Fatalf("llamada de prueba: %v", err)
+	}
+	if breaker.state != circuitClosed {
+		t.Errorf("el circuito debería estar cerrado, está en %d", breaker.state)
+	}
+	if _, err := chat(backend); err != nil {
+		t.Errorf("con el circuito cerrado: %v", err)
+	}
+}
+
+func TestBreakerHalfOpenFailureReopens(t *testing.T) {
+	server := newFlakyServer(t, status(http.StatusServiceUnavailable, ""))
+	breaker, clock := newTestBreaker(1)
+	backend := WithBreaker(NewOpenAI(Config{URL: server.URL}), breaker)
+
+	chat(backend)
+	clock.now = clock.now.Add(time.Minute)
+	if _, err := chat(backend); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("la llamada de prueba debería llegar al servidor, llegó %v", err)
+	}
+	if _, err := chat(backend); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("el circuito debería volver a abrirse, llegó %v", err)
+	}
+	if got := server.calls.Load(); got != 2 {
+		t.Errorf("se hicieron %d llamadas, se esperaban 2", got)
+	}
+}
+
+func TestBreakerHalfOpenAllowsOneCall(t *testing.T) {
+	breaker, clock := newTestBreaker(1)
+	breaker.record(&StatusError{StatusCode: http.StatusBadGateway})
+	clock.now = clock.now.Add(time.Minute)
+
+	if !breaker.allow() {
+		t.Fatal("pasado el cooldown debería pasar la llamada de prueba")
+	}
+	if breaker.allow() {
+		t.Error("con la llamada de prueba en curso no debería pasar otra")
+	}
+}
+
+func TestBreakerIgnoresClientErrors(t *testing.T) {
+	server := newFlakyServer(t, status(http.StatusBadRequest, ""))
+	breaker, _ := newTestBreaker(2)
+	backend := WithBreaker(NewOpenAI(Config{URL: server.URL}), breaker)
+
+	for i := 0; i < 5; i++ {
+		if _, err := chat(backend); errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("un 400 no debería abrir el circuito (llamada %d)", i+1)
+		}
+	}
+	if got := server.calls.Load(); got != 5 {
+		t.Errorf("se hicieron %d llamadas, se esperaban 5", got)
+	}
+}
+
+func TestRetryWithBreaker(t *testing.T) {
+	server := newFlakyServer(t, status(http.StatusServiceUnavailable, ""))
+	breaker, _ := newTestBreaker(2)
+	backend := WithRetry(WithBreaker(NewOpenAI(Config{URL: server.URL}), breaker), fastPolicy)
+
+	// El circuito se abre en el segundo intento y corta los reintentos
+	if _, err := chat(backend); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("se esperaba el circuito abierto, llegó %v", err)
+	}
+	if got := server.calls.Load(); got != 2 {
+		t.Errorf("se hicieron %d llamadas, se esperaban 2", got)
+	}
+}
@@ -0,0 +1,202 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/ivanneira/Lapislazuli/internal/logger"
+)
+
+// LlamaCpp habla con la API nativa del servidor de llama.cpp: arma el prompt
+// con /apply-template y completa con /completion, que acepta gramáticas GBNF.
+type LlamaCpp struct {
+	base       string
+	apiKey     string
+	structured string
+	client     *http.Client
+}
+
+// NewLlamaCpp crea el adaptador. cfg.URL es la base del servidor
+// (http://localhost:8080) o el endpoint /completion.
+func NewLlamaCpp(cfg Config) *LlamaCpp {
+	if cfg.Client == nil {
+		cfg.Client = defaultClient
+	}
+	return &LlamaCpp{
+		base:       baseURL(cfg.URL, "/completion"),
+		apiKey:     cfg.APIKey,
+		structured: cfg.StructuredOutput,
+		client:     cfg.Client,
+	}
+}
+
+type llamaCppCompletionRequest struct {
+	Prompt        string                 `json:"prompt"`
+	Stream        bool                   `json:"stream"`
+	NPredict      *int                   `json:"n_predict,omitempty"`
+	Temperature   *float32               `json:"temperature,omitempty"`
+	TopK          *int                   `json:"top_k,omitempty"`
+	TopP          *float32               `json:"top_p,omitempty"`
+	MinP          *float32               `json:"min_p,omitempty"`
+	RepeatPenalty *float32               `json:"repeat_penalty,omitempty"`
+	Grammar       string                 `json:"grammar,omitempty"`
+	JSONSchema    map[string]interface{} `json:"json_schema,omitempty"`
+	NProbs        int                    `json:"n_probs,omitempty"`
+}
+
+type llamaCppCompletionResponse struct {
+	Content         string `json:"content"`
+	Stop            bool   `json:"stop"`
+	TokensPredicted int    `json:"tokens_predicted"`
+	TokensEvaluated int    `json:"tokens_evaluated"`
+	// CompletionProbabilities usa el formato de llama.cpp con n_probs, igual
+	// al de OpenAI (token, logprob, top_logprobs).
+	CompletionProbabilities []TokenLogprob `json:"completion_probabilities"`
+}
+
+func (r llamaCppCompletionResponse) usage() Usage {
+	return Usage{
+		PromptTokens:     r.TokensEvaluated,
+		CompletionTokens: r.TokensPredicted,
+		TotalTokens:      r.TokensEvaluated + r.TokensPredicted,
+	}
+}
+
+// prompt aplica la plantilla de chat del modelo cargado a los mensajes.
+func (b *LlamaCpp) prompt(ctx context.Context, messages []Message) (string, error) {
+	var resp struct {
+		Prompt string `json:"prompt"`
+	}
+	body := map[string]interface{}{"messages": messages}
+	if err := doJSON(ctx, b.client, b.base+"/apply-template", b.apiKey, body, &resp); err != nil {
+		return "", err
+	}
+	return resp.Prompt, nil
+}
+
+func (b *LlamaCpp) newRequest(ctx context.Context, req ChatRequest) (*llamaCppCompletionRequest, error) {
+	if len(req.Tools) > 0 {
+		// /completion no sabe de herramientas; el servidor sí las soporta en
+		// su API compatible con OpenAI
+		return nil, Permanent(fmt.Errorf("la API nativa de llama.cpp no soporta tools: use el proveedor %s con /v1/chat/completions", ProviderOpenAI))
+	}
+	prompt, err := b.prompt(ctx, req.Messages)
+	if err != nil {
+		return nil, err
+	}
+	return &llamaCppCompletionRequest{
+		Prompt:        prompt,
+		NPredict:      req.Options.MaxTokens,
+		Temperature:   req.Options.Temperature,
+		TopK:          req.Options.TopK,
+		TopP:          req.Options.TopP,
+		MinP:          req.Options.MinP,
+		RepeatPenalty: req.Options.RepetitionPenalty,
+		NProbs:        req.TopLogprobs,
+	}, nil
+}
+
+func (b *LlamaCpp) send(ctx context.Context, body *llamaCppCompletionRequest) (*ChatResponse, error) {
+	var resp llamaCppCompletionResponse
+	if err := doJSON(ctx, b.client, b.base+"/completion", b.apiKey, body, &resp); err != nil {
+		return nil, err
+	}
+	return &ChatResponse{Content: resp.Content, Usage: resp.usage(), Logprobs: resp.CompletionProbabilities}, nil
+}
+
+func (b *LlamaCpp) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	body, err := b.newRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return b.send(ctx, body)
+}
+
+// Structured restringe la salida con la gramática GBNF del schema si la hay
+// y, si no o con StructuredJSONSchema, con el JSON Schema, que el servidor
+// convierte a gramática.
+func (b *LlamaCpp) Structured(ctx context.Context, req ChatRequest, schema Schema) (*ChatResponse, error) {
+	body, err := b.newRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if schema.Grammar != "" && b.structured != StructuredJSONSchema {
+		body.Grammar = schema.Grammar
+	} else {
+		body.JSONSchema = schema.Schema
+	}
+	return b.send(ctx, body)
+}
+
+func (b *LlamaCpp) Stream(ctx context.Context, req ChatRequest, onToken func(string)) (*ChatResponse, error) {
+	body, err := b.newRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	body.Stream = true
+	resp, err := postJSON(ctx, b.client, b.base+"/completion", b.apiKey, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	result := &ChatResponse{}
+	var content []byte
+	err = readSSE(resp.Body, func(data []byte) error {
+		var chunk llamaCppCompletionResponse
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			logger.Warn("Evento de streaming inválido: %s", data)
+			return nil
+		}
+		if chunk.Content != "" {
+			content = append(content, chunk.Content...)
+			if onToken != nil {
+				onToken(chunk.Content)
+			}
+		}
+		if chunk.Stop {
+			result.Usage = chunk.usage()
+		}
+		return nil
+	})
+	result.Content = string(content)
+	return result, err
+}
+
+// Embed llama a /embedding una vez por texto. Según la versión del servidor
+// la respuesta es {"embedding": [...]} o [{"embedding": [[...]]}].
+func (b *LlamaCpp) Embed(ctx context.Context, model string, input []string) ([][]float32, error) {
+	embeddings := make([][]float32, 0, len(input))
+	for _, text := range input {
+		var raw json.RawMessage
+		body := map[string]interface{}{"content": text}
+		if err := doJSON(ctx, b.client, b.base+"/embedding", b.apiKey, body, &raw); err != nil {
+			return nil, err
+		}
+		embedding, err := decodeLlamaCppEmbedding(raw)
+		if err != nil {
+			return nil, err
+		}
+		embeddings = append(embeddings, embedding)
+	}
+	return embeddings, nil
+}
+
+func decodeLlamaCppEmbedding(raw json.RawMessage) ([]float32, error) {
+	var single struct {
+		Embedding []float32 `json:"embedding"`
+	}
+	if err := json.Unmarshal(raw, &single); err == nil && len(single.Embedding) > 0 {
+		return single.Embedding, nil
+	}
+
+	var list []struct {
+		Embedding [][]float32 `json:"embedding"`
+	}
+	if err := json.Unmarshal(raw, &list); err == nil && len(list) > 0 && len(list[0].Embedding) > 0 {
+		return list[0].Embedding[0], nil
+	}
+	return nil, fmt.Errorf("respuesta de embedding de llama.cpp no reconocida")
+}
@@ -0,0 +1,132 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const llamaCppStream = `data: {"content":"ho","stop":false}
+
+data: {"content":"la","stop":false}
+
+data: {"content":"","stop":true,"tokens_evaluated":3,"tokens_predicted":2}
+
+`
+
+func newLlamaCppAPI(t *testing.T, contentType, completion, embedding string) *fakeAPI {
+	return newFakeAPI(t, contentType, map[string]string{
+		"/apply-template": `{"prompt":"<user>hola</user>"}`,
+		"/completion":     completion,
+		"/embedding":      embedding,
+	})
+}
+
+func TestLlamaCppChat(t *testing.T) {
+	api := newLlamaCppAPI(t, "application/json",
+		`{"content":"hola","stop":true,"tokens_evaluated":3,"tokens_predicted":1}`, "{}")
+	backend := NewLlamaCpp(Config{URL: api.URL + "/completion"})
+
+	resp, err := backend.Chat(context.Background(), testRequest())
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if resp.Content != "hola" || resp.Usage.TotalTokens != 4 {
+		t.Errorf("respuesta inesperada: %+v", resp)
+	}
+
+	template := api.last(t, "/apply-template")
+	if !reflect.DeepEqual(template["messages"], []interface{}{map[string]interface{}{"role": "user", "content": "hola"}}) {
+		t.Errorf("mensajes para la plantilla: %v", template["messages"])
+	}
+	body := api.last(t, "/completion")
+	if body["prompt"] != "<user>hola</user>" || body["temperature"] != 0.5 || body["stream"] != false {
+		t.Errorf("petición inesperada: %v", body)
+	}
+}
+
+func TestLlamaCppRejectsTools(t *testing.T) {
+	api := newLlamaCppAPI(t, "application/json", "{}", "{}")
+	req := testRequest()
+	req.Tools = []Tool{{Name: "hora"}}
+
+	_, err := NewLlamaCpp(Config{URL: api.URL}).Chat(context.Background(), req)
+	var permanent *permanentError
+	if !errors.As(err, &permanent) {
+		t.Fatalf("se esperaba un error permanente, llegó %v", err)
+	}
+	if api.count("/apply-template") != 0 {
+		t.Error("no debería llamarse al servidor")
+	}
+}
+
+func TestLlamaCppStream(t *testing.T) {
+	api := newLlamaCppAPI(t, "text/event-stream", llamaCppStream, "{}")
+	backend := NewLlamaCpp(Config{URL: api.URL})
+
+	var tokens []string
+	resp, err := backend.Stream(context.Background(), testRequest(), collect(&tokens))
+	if err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+	if !reflect.DeepEqual(tokens, []string{"ho", "la"}) {
+		t.Errorf("fragmentos = %q", tokens)
+	}
+	if resp.Content != "hola" || resp.Usage.TotalTokens != 5 {
+		t.Errorf("respuesta inesperada: %+v", resp)
+	}
+	if body := api.last(t, "/completion"); body["stream"] != true {
+		t.Errorf("petición inesperada: %v", body)
+	}
+}
+
+func TestLlamaCppStructured(t *testing.T) {
+	api := newLlamaCppAPI(t, "application/json", `{"content":"{}","stop":true}`, "{}")
+
+	if _, err := NewLlamaCpp(Config{URL: api.URL}).Structured(context.Background(), testRequest(), testSchema); err != nil {
+		t.Fatalf("Structured: %v", err)
+	}
+	body := api.last(t, "/completion")
+	if body["grammar"] != testSchema.Grammar {
+		t.Errorf("grammar = %v", body["grammar"])
+	}
+	if _, ok := body["json_schema"]; ok {
+		t.Errorf("no debería enviarse json_schema con gramática: %v", body)
+	}
+
+	jsonSchema := NewLlamaCpp(Config{URL: api.URL, StructuredOutput: StructuredJSONSchema})
+	if _, err := jsonSchema.Structured(context.Background(), testRequest(), testSchema); err != nil {
+		t.Fatalf("Structured con json_schema: %v", err)
+	}
+	body = api.last(t, "/completion")
+	if !reflect.DeepEqual(body["json_schema"], testSchema.Schema) {
+		t.Errorf("json_schema = %v", body["json_schema"])
+	}
+	if _, ok := body["grammar"]; ok {
+		t.Errorf("no debería enviarse grammar con json_schema: %v", body)
+	}
+}
+
+func TestLlamaCppEmbed(t *testing.T) {
+	for name, embedding := range map[string]string{
+		"objeto": `{"embedding":[0.1,0.2]}`,
+		"lista":  `[{"index":0,"embedding":[[0.1,0.2]]}]`,
+	} {
+		api := newLlamaCppAPI(t, "application/json", "{}", embedding)
+
+		embeddings, err := NewLlamaCpp(Config{URL: api.URL}).Embed(context.Background(), "emb", []string{"a", "b"})
+		if err != nil {
+			t.Fatalf("%s: Embed: %v", name, err)
+		}
+		if !reflect.DeepEqual(embeddings, [][]float32{{0.1, 0.2}, {0.1, 0.2}}) {
+			t.Errorf("%s: embeddings = %v", name, embeddings)
+		}
+		if got := api.count("/embedding"); got != 2 {
+			t.Errorf("%s: se hicieron %d llamadas, se esperaba una por texto", name, got)
+		}
+		if body := api.last(t, "/embedding"); body["content"] != "b" {
+			t.Errorf("%s: petición inesperada: %v", name, body)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+// Package llm define la interfaz común para hablar con servidores de modelos
+// de lenguaje y sus adaptadores: servidores compatibles con OpenAI (LM Studio,
+// vLLM, ...), la API nativa de Ollama y el servidor nativo de llama.cpp.
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ivanneira/Lapislazuli/internal/logger"
+)
+
+// Proveedores soportados.
+const (
+	ProviderOpenAI   = "openai"
+	ProviderOllama   = "ollama"
+	ProviderLlamaCpp = "llamacpp"
+)
+
+// Formas de restringir una respuesta estructurada.
+const (
+	StructuredJSONSchema = "json_schema" // JSON Schema (response_format, format)
+	StructuredGrammar    = "grammar"     // gramática GBNF
+)
+
+// Message es un mensaje de chat. ToolCalls son las herramientas que pidió
+// el modelo en un mensaje del asistente; ToolCallID indica a qué llamada
+// responde un mensaje con rol "tool".
+type Message struct {
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+}
+
+// Options son los parámetros de muestreo. Los nil no se envían.
+type Options struct {
+	Temperature       *float32
+	MaxTokens         *int
+	TopK              *int
+	TopP              *float32
+	MinP              *float32
+	RepetitionPenalty *float32
+}
+
+// Schema describe la salida estructurada pedida al modelo.
+type Schema struct {
+	Name   string
+	Schema map[string]interface{}
+	// Grammar es una gramática GBNF equivalente, para los backends que la soportan.
+	Grammar string
+}
+
+// ChatRequest es una petición de chat independiente del proveedor.
+type ChatRequest struct {
+	Model    string
+	Messages []Message
+	Options  Options
+	// TopLogprobs pide las probabilidades de cada token generado y de las
+	// TopLogprobs alternativas más probables. 0 no las pide.
+	TopLogprobs int
+	// Tools son las herramientas que el modelo puede pedir en Chat.
+	Tools []Tool
+}
+
+// Usage informa los tokens consumidos por una petición.
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// Add devuelve la suma de u y other.
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		PromptTokens:     u.PromptTokens + other.PromptTokens,
+		CompletionTokens: u.CompletionTokens + other.CompletionTokens,
+		TotalTokens:      u.TotalTokens + other.TotalTokens,
+	}
+}
+
+// TopLogprob es una alternativa para un token generado.
+type TopLogprob struct {
+	Token   string  `json:"token"`
+	Logprob float64 `json:"logprob"`
+}
+
+// TokenLogprob es un token generado con su log-probabilidad.
+type TokenLogprob struct {
+	Token       string       `json:"token"`
+	Logprob     float64      `json:"logprob"`
+	TopLogprobs []TopLogprob `json:"top_logprobs"`
+}
+
+// ChatResponse es la respuesta del modelo. Logprobs solo está presente si se
+// pidió y el servidor lo soporta. Si el modelo pidió herramientas, están en
+// ToolCalls y Content puede estar vacío.
+type ChatResponse struct {
+	Content   string
+	Usage     Usage
+	Logprobs  []TokenLogprob
+	ToolCalls []ToolCall
+}
+
+// Backend es un servidor de modelos de lenguaje.
+type Backend interface {
+	// Chat genera una respuesta de texto libre.
+	Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error)
+	// Stream es como Chat pero llama a onToken con cada fragmento a medida que llega.
+	Stream(ctx context.Context, req ChatRequest, onToken func(string)) (*ChatResponse, error)
+	// Structured genera una respuesta JSON que respeta schema.
+	Structured(ctx context.Context, req ChatRequest, schema Schema) (*ChatResponse, error)
+	// Embed devuelve un embedding por cada texto de input.
+	Embed(ctx context.Context, model string, input []string) ([][]float32, error)
+}
+
+// Config son los datos para crear un Backend.
+type Config struct {
+	Provider string
+	URL      string
+	APIKey   string
+	// StructuredOutput fuerza json_schema o grammar en Structured. Vacío usa
+	// lo que la API soporta de forma nativa.
+	StructuredOutput string
+	// StrictSchema pide que el servidor respete el JSON Schema al pie de la
+	// letra (el modo strict de OpenAI) en Structured y en las herramientas.
+	// Los esquemas se adaptan a las reglas de ese modo antes de enviarse.
+	StrictSchema bool
+	// Client permite usar un *http.Client propio, por ejemplo en pruebas.
+	Client *http.Client
+}
+
+var defaultClient = &http.Client{
+	Timeout: 5 * time.Minute,
+	Transport: &http.Transport{
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 100,
+		IdleConnTimeout:     90 * time.Second,
+	},
+}
+
+// New crea el Backend del proveedor indicado. Sin proveedor se asume un
+// servidor compatible con OpenAI.
+func New(cfg Config) (Backend, error) {
+	if cfg.Client == nil {
+		cfg.Client = defaultClient
+	}
+	switch cfg.StructuredOutput {
+	case "", StructuredJSONSchema, StructuredGrammar:
+	default:
+		return nil, fmt.Errorf("forma de salida estructurada desconocida: %s", cfg.StructuredOutput)
+	}
+	switch cfg.Provider {
+	case "", ProviderOpenAI:
+		return NewOpenAI(cfg), nil
+	case ProviderOllama:
+		return NewOllama(cfg), nil
+	case ProviderLlamaCpp:
+		return NewLlamaCpp(cfg), nil
+	default:
+		return nil, fmt.Errorf("proveedor de modelos desconocido: %s", cfg.Provider)
+	}
+}
+
+// StatusError es una respuesta HTTP distinta de 200 del servidor de modelos.
+type StatusError struct {
+	StatusCode int
+	Body       string
+	Header     http.Header
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("error en la llamada al modelo (HTTP %d): %s", e.StatusCode, e.Body)
+}
+
+// baseURL quita de url el sufijo de endpoint indicado, para aceptar tanto la
+// URL base del servidor como la del endpoint completo.
+func baseURL(url string, suffixes ...string) string {
+	url = strings.TrimRight(url, "/")
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(url, suffix) {
+			return strings.TrimSuffix(url, suffix)
+		}
+	}
+	return url
+}
+
+// postJSON envía body como JSON y devuelve la respuesta si el estado es 200.
+// El llamador debe cerrar el cuerpo.
+func postJSON(ctx context.Context, client *http.Client, url, apiKey string, body interface{}) (*http.Response, error) {
+	logger.JSON("Request body", body)
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Debug("URL destino: %s", url)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		logger.Error("HTTP request error: %v", err)
+		return nil, err
+	}
+	logger.Info("Respuesta recibida, estado: %d", resp.StatusCode)
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		logger.Error("Error en la llamada al modelo: %s", string(bodyBytes))
+		return nil, &StatusError{StatusCode: resp.StatusCode, Body: string(bodyBytes), Header: resp.Header}
+	}
+	return resp, nil
+}
+
+// doJSON envía body y decodifica la respuesta en out.
+func doJSON(ctx context.Context, client *http.Client, url, apiKey string, body, out interface{}) error {
+	resp, err := postJSON(ctx, client, url, apiKey, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		logger.Error("Error decodificando respuesta: %v", err)
+		return err
+	}
+	logger.JSON("Respuesta completa", out)
+	return nil
+}
+
+// readLines llama a fn con cada línea no vacía de r. Si fn devuelve false se
+// deja de leer.
+func readLines(r io.Reader, fn func(line string) bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !fn(line) {
+			break
+		}
+	}
+	return scanner.Err()
+}
+
+// readSSE llama a fn con el contenido de cada evento "data:" hasta "[DONE]".
+func readSSE(r io.Reader, fn func(data []byte) error) error {
+	var fnErr error
+	err := readLines(r, func(line string) bool {
+		if !strings.HasPrefix(line, "data:") {
+			return true
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			return false
+		}
+		fnErr = fn([]byte(data))
+		return fnErr == nil
+	})
+	if fnErr != nil {
+		return fnErr
+	}
+	return err
+}
@@ -0,0 +1,94 @@
+package llm
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// fakeAPI es un servidor de modelos falso que responde cada ruta con un
+// cuerpo fijo y guarda los cuerpos JSON que recibe, por ruta.
+type fakeAPI struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests map[string][]map[string]interface{}
+	headers  map[string]http.Header
+}
+
+// newFakeAPI crea el servidor. routes va de la ruta al cuerpo de la
+// respuesta, que se envía con contentType.
+func newFakeAPI(t *testing.T, contentType string, routes map[string]string) *fakeAPI {
+	t.Helper()
+	api := &fakeAPI{requests: map[string][]map[string]interface{}{}, headers: map[string]http.Header{}}
+	api.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := routes[r.URL.Path]
+		if !ok || r.Method != http.MethodPost {
+			http.NotFound(w, r)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		var decoded map[string]interface{}
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Errorf("%s: cuerpo inválido: %v", r.URL.Path, err)
+		}
+		api.mu.Lock()
+		api.requests[r.URL.Path] = append(api.requests[r.URL.Path], decoded)
+		api.headers[r.URL.Path] = r.Header.Clone()
+		api.mu.Unlock()
+
+		w.Header().Set("Content-Type", contentType)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(api.Close)
+	return api
+}
+
+// last devuelve el último cuerpo recibido en path.
+func (a *fakeAPI) last(t *testing.T, path string) map[string]interface{} {
+	t.Helper()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	requests := a.requests[path]
+	if len(requests) == 0 {
+		t.Fatalf("no llegó ninguna petición a %s", path)
+	}
+	return requests[len(requests)-1]
+}
+
+// count devuelve cuántas peticiones llegaron a path.
+func (a *fakeAPI) count(path string) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.requests[path])
+}
+
+// testSchema es el esquema de las pruebas de salida estructurada.
+var testSchema = Schema{
+	Name: "clasificacion",
+	Schema: map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"accion": map[string]interface{}{"type": "string"}},
+	},
+	Grammar: `root ::= "{}"`,
+}
+
+// testRequest es una petición con un parámetro de muestreo.
+func testRequest() ChatRequest {
+	temperature := float32(0.5)
+	return ChatRequest{
+		Model:    "modelo",
+		Messages: []Message{{Role: "user", Content: "hola"}},
+		Options:  Options{Temperature: &temperature},
+	}
+}
+
+// collect devuelve un onToken que junta los fragmentos recibidos.
+func collect(tokens *[]string) func(string) {
+	return func(token string) {
+		*tokens = append(*tokens, token)
+	}
+}
@@ -0,0 +1,233 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Ollama habla con la API nativa de Ollama (/api/chat y /api/embed).
+type Ollama struct {
+	base       string
+	structured string
+	client     *http.Client
+}
+
+// NewOllama crea el adaptador. cfg.URL es la base del servidor
+// (http://localhost:11434) o el endpoint /api/chat.
+func NewOllama(cfg Config) *Ollama {
+	if cfg.Client == nil {
+		cfg.Client = defaultClient
+	}
+	return &Ollama{
+		base:       baseURL(cfg.URL, "/api/chat"),
+		structured: cfg.StructuredOutput,
+		client:     cfg.Client,
+	}
+}
+
+type ollamaChatRequest struct {
+	Model    string                 `json:"model"`
+	Messages []ollamaMessage        `json:"messages"`
+	Stream   bool                   `json:"stream"`
+	Format   interface{}            `json:"format,omitempty"`
+	Options  map[string]interface{} `json:"options,omitempty"`
+	Tools    []openAITool           `json:"tools,omitempty"`
+}
+
+// ollamaMessage es un Message en el formato de Ollama: los argumentos de
+// las herramientas van como objeto JSON y las respuestas de herramientas
+// llevan el nombre de la herramienta en lugar del id de la llamada.
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+}
+
+type ollamaToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+type ollamaChatResponse struct {
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+	Error           string        `json:"error"`
+}
+
+// ollamaMessages convierte los mensajes al formato de Ollama.
+func ollamaMessages(messages []Message) []ollamaMessage {
+	names := map[string]string{}
+	converted := make([]ollamaMessage, len(messages))
+	for i, msg := range messages {
+		converted[i] = ollamaMessage{Role: msg.Role, Content: msg.Content}
+		for _, call := range msg.ToolCalls {
+			names[call.ID] = call.Function.Name
+			var tc ollamaToolCall
+			tc.Function.Name = call.Function.Name
+			tc.Function.Arguments = json.RawMessage(call.Function.Arguments)
+			if !json.Valid(tc.Function.Arguments) {
+				tc.Function.Arguments = json.RawMessage("{}")
+			}
+			converted[i].ToolCalls = append(converted[i].ToolCalls, tc)
+		}
+		if msg.ToolCallID != "" {
+			converted[i].ToolName = names[msg.ToolCallID]
+		}
+	}
+	return converted
+}
+
+// toolCalls convierte las llamadas de Ollama, que no traen id, al formato
+// común.
+func (m ollamaMessage) toolCalls() []ToolCall {
+	var calls []ToolCall
+	for i, tc := range m.ToolCalls {
+		arguments := string(tc.Function.Arguments)
+		if arguments == "" {
+			arguments = "{}"
+		}
+		calls = append(calls, ToolCall{
+			ID:       fmt.Sprintf("call_%d", i),
+			Type:     "function",
+			Function: ToolFunction{Name: tc.Function.Name, Arguments: arguments},
+		})
+	}
+	return calls
+}
+
+func (r ollamaChatResponse) usage() Usage {
+	return Usage{
+		PromptTokens:     r.PromptEvalCount,
+		CompletionTokens: r.EvalCount,
+		TotalTokens:      r.PromptEvalCount + r.EvalCount,
+	}
+}
+
+// ollamaOptions traduce los parámetros de muestreo a los nombres de Ollama.
+func ollamaOptions(o Options) map[string]interface{} {
+	options := map[string]interface{}{}
+	if o.Temperature != nil {
+		options["temperature"] = *o.Temperature
+	}
+	if o.MaxTokens != nil {
+		options["num_predict"] = *o.MaxTokens
+	}
+	if o.TopK != nil {
+		options["top_k"] = *o.TopK
+	}
+	if o.TopP != nil {
+		options["top_p"] = *o.TopP
+	}
+	if o.MinP != nil {
+		options["min_p"] = *o.MinP
+	}
+	if o.RepetitionPenalty != nil {
+		options["repeat_penalty"] = *o.RepetitionPenalty
+	}
+	if len(options) == 0 {
+		return nil
+	}
+	return options
+}
+
+func (b *Ollama) newRequest(req ChatRequest) ollamaChatRequest {
+	return ollamaChatRequest{
+		Model:    req.Model,
+		Messages: ollamaMessages(req.Messages),
+		Options:  ollamaOptions(req.Options),
+		Tools:    openAITools(req.Tools, false),
+	}
+}
+
+func (b *Ollama) send(ctx context.Context, body ollamaChatRequest) (*ChatResponse, error) {
+	var resp ollamaChatResponse
+	if err := doJSON(ctx, b.client, b.base+"/api/chat", "", body, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("error de Ollama: %s", resp.Error)
+	}
+	return &ChatResponse{Content: resp.Message.Content, Usage: resp.usage(), ToolCalls: resp.Message.toolCalls()}, nil
+}
+
+func (b *Ollama) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	return b.send(ctx, b.newRequest(req))
+}
+
+// Structured usa el campo format de Ollama, que acepta un JSON Schema.
+// Ollama no soporta gramáticas GBNF: con StructuredGrammar solo se pide JSON.
+func (b *Ollama) Structured(ctx context.Context, req ChatRequest, schema Schema) (*ChatResponse, error) {
+	body := b.newRequest(req)
+	if b.structured == StructuredGrammar {
+		body.Format = "json"
+	} else {
+		body.Format = schema.Schema
+	}
+	return b.send(ctx, body)
+}
+
+// Stream lee la respuesta de Ollama, que llega como un objeto JSON por línea.
+func (b *Ollama) Stream(ctx context.Context, req ChatRequest, onToken func(string)) (*ChatResponse, error) {
+	body := b.newRequest(req)
+	body.Stream = true
+	resp, err := postJSON(ctx, b.client, b.base+"/api/chat", "", body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	result := &ChatResponse{}
+	var content []byte
+	var streamErr error
+	err = readLines(resp.Body, func(line string) bool {
+		var chunk ollamaChatResponse
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			streamErr = err
+			return false
+		}
+		if chunk.Error != "" {
+			streamErr = fmt.Errorf("error de Ollama: %s", chunk.Error)
+			return false
+		}
+		if chunk.Message.Content != "" {
+			content = append(content, chunk.Message.Content...)
+			if onToken != nil {
+				onToken(chunk.Message.Content)
+			}
+		}
+		if chunk.Done {
+			result.Usage = chunk.usage()
+			return false
+		}
+		return true
+	})
+	result.Content = string(content)
+	if streamErr != nil {
+		return result, streamErr
+	}
+	return result, err
+}
+
+func (b *Ollama) Embed(ctx context.Context, model string, input []string) ([][]float32, error) {
+	body := map[string]interface{}{
+		"model": model,
+		"input": input,
+	}
+	var resp struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	if err := doJSON(ctx, b.client, b.base+"/api/embed", "", body, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Embeddings) != len(input) {
+		return nil, fmt.Errorf("se esperaban %d embeddings y llegaron %d", len(input), len(resp.Embeddings))
+	}
+	return resp.Embeddings, nil
+}
@@ -0,0 +1,117 @@
+package llm
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+const ollamaStream = `{"message":{"role":"assistant","content":"ho"},"done":false}
+{"message":{"role":"assistant","content":"la"},"done":false}
+{"message":{"role":"assistant","content":""},"done":true,"prompt_eval_count":3,"eval_count":2}
+`
+
+func newOllamaAPI(t *testing.T, chat string) *fakeAPI {
+	return newFakeAPI(t, "application/json", map[string]string{
+		"/api/chat":  chat,
+		"/api/embed": `{"embeddings":[[0.1,0.2],[0.3,0.4]]}`,
+	})
+}
+
+func TestOllamaChat(t *testing.T) {
+	api := newOllamaAPI(t, `{"message":{"role":"assistant","content":"hola"},"done":true,"prompt_eval_count":3,"eval_count":1}`)
+	backend := NewOllama(Config{URL: api.URL + "/api/chat"})
+
+	resp, err := backend.Chat(context.Background(), testRequest())
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if resp.Content != "hola" || resp.Usage != (Usage{PromptTokens: 3, CompletionTokens: 1, TotalTokens: 4}) {
+		t.Errorf("respuesta inesperada: %+v", resp)
+	}
+
+	body := api.last(t, "/api/chat")
+	if body["model"] != "modelo" || body["stream"] != false ||
+		!reflect.DeepEqual(body["options"], map[string]interface{}{"temperature": 0.5}) {
+		t.Errorf("petición inesperada: %v", body)
+	}
+}
+
+func TestOllamaChatError(t *testing.T) {
+	api := newOllamaAPI(t, `{"error":"modelo no encontrado"}`)
+
+	if _, err := NewOllama(Config{URL: api.URL}).Chat(context.Background(), testRequest()); err == nil {
+		t.Fatal("se esperaba el error de Ollama")
+	}
+}
+
+func TestOllamaStream(t *testing.T) {
+	api := newFakeAPI(t, "application/x-ndjson", map[string]string{"/api/chat": ollamaStream})
+	backend := NewOllama(Config{URL: api.URL})
+
+	var tokens []string
+	resp, err := backend.Stream(context.Background(), testRequest(), collect(&tokens))
+	if err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+	if !reflect.DeepEqual(tokens, []string{"ho", "la"}) {
+		t.Errorf("fragmentos = %q", tokens)
+	}
+	if resp.Content != "hola" || resp.Usage.TotalTokens != 5 {
+		t.Errorf("respuesta inesperada: %+v", resp)
+	}
+	if body := api.last(t, "/api/chat"); body["stream"] != true {
+		t.Errorf("petición inesperada: %v", body)
+	}
+}
+
+func TestOllamaStreamError(t *testing.T) {
+	api := newFakeAPI(t, "application/x-ndjson", map[string]string{
+		"/api/chat": `{"message":{"role":"assistant","content":"ho"},"done":false}
+{"error":"sin memoria"}
+`,
+	})
+
+	var tokens []string
+	resp, err := NewOllama(Config{URL: api.URL}).Stream(context.Background(), testRequest(), collect(&tokens))
+	if err == nil {
+		t.Fatal("se esperaba el error del stream")
+	}
+	if resp == nil || resp.Content != "ho" {
+		t.Errorf("debería devolverse lo recibido antes del error: %+v", resp)
+	}
+}
+
+func TestOllamaStructured(t *testing.T) {
+	api := newOllamaAPI(t, `{"message":{"role":"assistant","content":"{}"},"done":true}`)
+
+	if _, err := NewOllama(Config{URL: api.URL}).Structured(context.Background(), testRequest(), testSchema); err != nil {
+		t.Fatalf("Structured: %v", err)
+	}
+	if body := api.last(t, "/api/chat"); !reflect.DeepEqual(body["format"], testSchema.Schema) {
+		t.Errorf("format = %v, se esperaba el JSON Schema", body["format"])
+	}
+
+	grammar := NewOllama(Config{URL: api.URL, StructuredOutput: StructuredGrammar})
+	if _, err := grammar.Structured(context.Background(), testRequest(), testSchema); err != nil {
+		t.Fatalf("Structured con gramática: %v", err)
+	}
+	if body := api.last(t, "/api/chat"); body["format"] != "json" {
+		t.Errorf("format = %v, se esperaba json", body["format"])
+	}
+}
+
+func TestOllamaEmbed(t *testing.T) {
+	api := newOllamaAPI(t, "{}")
+
+	embeddings, err := NewOllama(Config{URL: api.URL}).Embed(context.Background(), "emb", []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	if !reflect.DeepEqual(embeddings, [][]float32{{0.1, 0.2}, {0.3, 0.4}}) {
+		t.Errorf("embeddings = %v", embeddings)
+	}
+	if body := api.last(t, "/api/embed"); body["model"] != "emb" || !reflect.DeepEqual(body["input"], []interface{}{"a", "b"}) {
+		t.Errorf("petición inesperada: %v", body)
+	}
+}
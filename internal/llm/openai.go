@@ -0,0 +1,199 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/ivanneira/Lapislazuli/internal/logger"
+)
+
+// OpenAI habla con servidores compatibles con la API de OpenAI
+// (/v1/chat/completions y /v1/embeddings), como LM Studio.
+type OpenAI struct {
+	base       string
+	apiKey     string
+	structured string
+	strict     bool
+	client     *http.Client
+}
+
+// NewOpenAI crea el adaptador. cfg.URL puede ser la base (http://host/v1) o
+// el endpoint completo de chat.
+func NewOpenAI(cfg Config) *OpenAI {
+	if cfg.Client == nil {
+		cfg.Client = defaultClient
+	}
+	return &OpenAI{
+		base:       baseURL(cfg.URL, "/chat/completions"),
+		apiKey:     cfg.APIKey,
+		structured: cfg.StructuredOutput,
+		strict:     cfg.StrictSchema,
+		client:     cfg.Client,
+	}
+}
+
+type openAIResponseFormat struct {
+	Type       string                 `json:"type"`
+	JSONSchema map[string]interface{} `json:"json_schema,omitempty"`
+}
+
+type openAIChatRequest struct {
+	Model             string                `json:"model"`
+	Messages          []Message             `json:"messages"`
+	ResponseFormat    *openAIResponseFormat `json:"response_format,omitempty"`
+	Grammar           string                `json:"grammar,omitempty"`
+	Temperature       *float32              `json:"temperature,omitempty"`
+	MaxTokens         *int                  `json:"max_tokens,omitempty"`
+	Stream            bool                  `json:"stream"`
+	StreamOptions     map[string]bool       `json:"stream_options,omitempty"`
+	TopK              *int                  `json:"top_k,omitempty"`
+	TopP              *float32              `json:"top_p,omitempty"`
+	MinP              *float32              `json:"min_p,omitempty"`
+	RepetitionPenalty *float32              `json:"repetition_penalty,omitempty"`
+	Logprobs          bool                  `json:"logprobs,omitempty"`
+	TopLogprobs       int                   `json:"top_logprobs,omitempty"`
+	Tools             []openAITool          `json:"tools,omitempty"`
+}
+
+type openAIChatResponse struct {
+	Choices []struct {
+		Message  Message `json:"message"`
+		Logprobs *struct {
+			Content []TokenLogprob `json:"content"`
+		} `json:"logprobs"`
+	} `json:"choices"`
+	Usage Usage `json:"usage"`
+}
+
+type openAIStreamChunk struct {
+	Choices []struct {
+		Delta Message `json:"delta"`
+	} `json:"choices"`
+	Usage *Usage `json:"usage"`
+}
+
+func (b *OpenAI) newRequest(req ChatRequest) openAIChatRequest {
+	return openAIChatRequest{
+		Model:             req.Model,
+		Messages:          req.Messages,
+		Temperature:       req.Options.Temperature,
+		MaxTokens:         req.Options.MaxTokens,
+		TopK:              req.Options.TopK,
+		TopP:              req.Options.TopP,
+		MinP:              req.Options.MinP,
+		RepetitionPenalty: req.Options.RepetitionPenalty,
+		Logprobs:          req.TopLogprobs > 0,
+		TopLogprobs:       req.TopLogprobs,
+		Tools:             openAITools(req.Tools, b.strict),
+	}
+}
+
+func (b *OpenAI) send(ctx context.Context, body openAIChatRequest) (*ChatResponse, error) {
+	var resp openAIChatResponse
+	if err := doJSON(ctx, b.client, b.base+"/chat/completions", b.apiKey, body, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("no se recibieron respuestas del modelo")
+	}
+	result := &ChatResponse{
+		Content:   resp.Choices[0].Message.Content,
+		Usage:     resp.Usage,
+		ToolCalls: resp.Choices[0].Message.ToolCalls,
+	}
+	if logprobs := resp.Choices[0].Logprobs; logprobs != nil {
+		result.Logprobs = logprobs.Content
+	}
+	return result, nil
+}
+
+func (b *OpenAI) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	return b.send(ctx, b.newRequest(req))
+}
+
+// Structured usa response_format con json_schema, en modo strict solo si se
+// configuró StrictSchema. Con StructuredGrammar envía en cambio la gramática
+// GBNF en el campo grammar, que aceptan servidores como llama.cpp aunque no
+// soporten json_schema.
+func (b *OpenAI) Structured(ctx context.Context, req ChatRequest, schema Schema) (*ChatResponse, error) {
+	body := b.newRequest(req)
+	if b.structured == StructuredGrammar && schema.Grammar != "" {
+		body.Grammar = schema.Grammar
+		return b.send(ctx, body)
+	}
+	jsonSchema := map[string]interface{}{
+		"name":   schema.Name,
+		"schema": schema.Schema,
+	}
+	if b.strict {
+		jsonSchema["strict"] = true
+		jsonSchema["schema"] = strictSchema(schema.Schema)
+	}
+	body.ResponseFormat = &openAIResponseFormat{Type: "json_schema", JSONSchema: jsonSchema}
+	return b.send(ctx, body)
+}
+
+func (b *OpenAI) Stream(ctx context.Context, req ChatRequest, onToken func(string)) (*ChatResponse, error) {
+	body := b.newRequest(req)
+	body.Stream = true
+	body.StreamOptions = map[string]bool{"include_usage": true}
+	resp, err := postJSON(ctx, b.client, b.base+"/chat/completions", b.apiKey, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	result := &ChatResponse{}
+	var content []byte
+	err = readSSE(resp.Body, func(data []byte) error {
+		var chunk openAIStreamChunk
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			logger.Warn("Evento de streaming inválido: %s", data)
+			return nil
+		}
+		if chunk.Usage != nil {
+			result.Usage = *chunk.Usage
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content == "" {
+				continue
+			}
+			content = append(content, choice.Delta.Content...)
+			if onToken != nil {
+				onToken(choice.Delta.Content)
+			}
+		}
+		return nil
+	})
+	result.Content = string(content)
+	return result, err
+}
+
+func (b *OpenAI) Embed(ctx context.Context, model string, input []string) ([][]float32, error) {
+	body := map[string]interface{}{
+		"model": model,
+		"input": input,
+	}
+	var resp struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := doJSON(ctx, b.client, b.base+"/embeddings", b.apiKey, body, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Data) != len(input) {
+		return nil, fmt.Errorf("se esperaban %d embeddings y llegaron %d", len(input), len(resp.Data))
+	}
+	embeddings := make([][]float32, len(input))
+	for _, d := range resp.Data {
+		if d.Index < 0 || d.Index >= len(input) {
+			return nil, fmt.Errorf("índice de embedding inválido: %d", d.Index)
+		}
+		embeddings[d.Index] = d.Embedding
+	}
+	return embeddings, nil
+}
@@ -0,0 +1,213 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const openAIStream = `data: {"choices":[{"delta":{"role":"assistant","content":""}}]}
+
+data: {"choices":[{"delta":{"content":"ho"}}]}
+
+data: {"choices":[{"delta":{"content":"la"}}]}
+
+data: {"choices":[],"usage":{"prompt_tokens":3,"completion_tokens":2,"total_tokens":5}}
+
+data: [DONE]
+
+`
+
+func newOpenAIAPI(t *testing.T, chat string) *fakeAPI {
+	return newFakeAPI(t, "application/json", map[string]string{
+		"/v1/chat/completions": chat,
+		"/v1/embeddings":       `{"data":[{"index":1,"embedding":[0.3,0.4]},{"index":0,"embedding":[0.1,0.2]}]}`,
+	})
+}
+
+func TestOpenAIChat(t *testing.T) {
+	api := newOpenAIAPI(t, `{"choices":[{"message":{"role":"assistant","content":"hola"}}],"usage":{"prompt_tokens":3,"completion_tokens":1,"total_tokens":4}}`)
+	backend := NewOpenAI(Config{URL: api.URL + "/v1/chat/completions", APIKey: "clave"})
+
+	resp, err := backend.Chat(context.Background(), testRequest())
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if resp.Content != "hola" || resp.Usage.TotalTokens != 4 {
+		t.Errorf("respuesta inesperada: %+v", resp)
+	}
+
+	body := api.last(t, "/v1/chat/completions")
+	if body["model"] != "modelo" || body["temperature"] != 0.5 || body["stream"] != false {
+		t.Errorf("petición inesperada: %v", body)
+	}
+	if _, ok := body["top_k"]; ok {
+		t.Errorf("no debería enviarse top_k sin definir: %v", body)
+	}
+	if got := api.headers["/v1/chat/completions"].Get("Authorization"); got != "Bearer clave" {
+		t.Errorf("Authorization = %q", got)
+	}
+}
+
+func TestOpenAIStream(t *testing.T) {
+	api := newFakeAPI(t, "text/event-stream", map[string]string{"/v1/chat/completions": openAIStream})
+	backend := NewOpenAI(Config{URL: api.URL + "/v1"})
+
+	var tokens []string
+	resp, err := backend.Stream(context.Background(), testRequest(), collect(&tokens))
+	if err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+	if !reflect.DeepEqual(tokens, []string{"ho", "la"}) {
+		t.Errorf("fragmentos = %q", tokens)
+	}
+	if resp.Content != "hola" || resp.Usage.TotalTokens != 5 {
+		t.Errorf("respuesta inesperada: %+v", resp)
+	}
+
+	body := api.last(t, "/v1/chat/completions")
+	if body["stream"] != true || !reflect.DeepEqual(body["stream_options"], map[string]interface{}{"include_usage": true}) {
+		t.Errorf("petición inesperada: %v", body)
+	}
+}
+
+func TestOpenAIStructured(t *testing.T) {
+	api := newOpenAIAPI(t, `{"choices":[{"message":{"role":"assistant","content":"{}"}}]}`)
+
+	if _, err := NewOpenAI(Config{URL: api.URL + "/v1"}).Structured(context.Background(), testRequest(), testSchema); err != nil {
+		t.Fatalf("Structured: %v", err)
+	}
+	body := api.last(t, "/v1/chat/completions")
+	format, _ := body["response_format"].(map[string]interface{})
+	jsonSchema, _ := format["json_schema"].(map[string]interface{})
+	if format["type"] != "json_schema" || jsonSchema["name"] != "clasificacion" ||
+		!reflect.DeepEqual(jsonSchema["schema"], testSchema.Schema) {
+		t.Errorf("response_format inesperado: %v", body["response_format"])
+	}
+	if _, ok := jsonSchema["strict"]; ok {
+		t.Errorf("sin StrictSchema no debería pedirse el modo strict: %v", jsonSchema)
+	}
+	if _, ok := body["grammar"]; ok {
+		t.Errorf("no debería enviarse grammar: %v", body)
+	}
+
+	grammar := NewOpenAI(Config{URL: api.URL + "/v1", StructuredOutput: StructuredGrammar})
+	if _, err := grammar.Structured(context.Background(), testRequest(), testSchema); err != nil {
+		t.Fatalf("Structured con gramática: %v", err)
+	}
+	body = api.last(t, "/v1/chat/completions")
+	if body["grammar"] != testSchema.Grammar {
+		t.Errorf("grammar = %v", body["grammar"])
+	}
+	if _, ok := body["response_format"]; ok {
+		t.Errorf("no debería enviarse response_format con gramática: %v", body)
+	}
+}
+
+// looseSchema tiene lo que el modo strict no admite: propiedades opcionales,
+// objetos sin additionalProperties, const, oneOf y un objeto sin propiedades.
+func looseSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"accion":    map[string]interface{}{"type": "string", "const": "luz"},
+			"confianza": map[string]interface{}{"type": "number"},
+			"modo":      map[string]interface{}{"type": "string", "enum": []string{"a", "b"}},
+			"params":    map[string]interface{}{"type": "object"},
+			"valor":     map[string]interface{}{"oneOf": []interface{}{map[string]interface{}{"type": "string"}, map[string]interface{}{"type": "integer"}}},
+			"pasos": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{"id": map[string]interface{}{"type": "string"}},
+				},
+			},
+		},
+		"required": []string{"accion", "params"},
+	}
+}
+
+// strictLooseSchema es looseSchema adaptado al modo strict, como JSON.
+const strictLooseSchema = `{
+	"type": "object",
+	"properties": {
+		"accion": {"type": "string", "enum": ["luz"]},
+		"confianza": {"type": ["number", "null"]},
+		"modo": {"type": ["string", "null"], "enum": ["a", "b", null]},
+		"params": {"type": "object", "properties": {}, "required": [], "additionalProperties": false},
+		"valor": {"anyOf": [{"type": "string"}, {"type": "integer"}, {"type": "null"}]},
+		"pasos": {
+			"type": ["array", "null"],
+			"items": {
+				"type": "object",
+				"properties": {"id": {"type": ["string", "null"]}},
+				"required": ["id"],
+				"additionalProperties": false
+			}
+		}
+	},
+	"required": ["accion", "confianza", "modo", "params", "pasos", "valor"],
+	"additionalProperties": false
+}`
+
+func TestOpenAIStrictSchema(t *testing.T) {
+	api := newOpenAIAPI(t, `{"choices":[{"message":{"role":"assistant","content":"{}"}}]}`)
+	backend := NewOpenAI(Config{URL: api.URL + "/v1", StrictSchema: true})
+	var want interface{}
+	if err := json.Unmarshal([]byte(strictLooseSchema), &want); err != nil {
+		t.Fatal(err)
+	}
+
+	schema := looseSchema()
+	if _, err := backend.Structured(context.Background(), testRequest(), Schema{Name: "s", Schema: schema}); err != nil {
+		t.Fatalf("Structured: %v", err)
+	}
+	if !reflect.DeepEqual(schema, looseSchema()) {
+		t.Error("no debería modificarse el esquema original")
+	}
+	format, _ := api.last(t, "/v1/chat/completions")["response_format"].(map[string]interface{})
+	jsonSchema, _ := format["json_schema"].(map[string]interface{})
+	if jsonSchema["strict"] != true {
+		t.Errorf("se esperaba strict: true: %v", jsonSchema)
+	}
+	if !reflect.DeepEqual(jsonSchema["schema"], want) {
+		t.Errorf("esquema strict inesperado:\n%v\nse esperaba:\n%v", jsonSchema["schema"], want)
+	}
+
+	req := testRequest()
+	req.Tools = []Tool{{Name: "buscar", Parameters: looseSchema()}}
+	if _, err := backend.Chat(context.Background(), req); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	tools, _ := api.last(t, "/v1/chat/completions")["tools"].([]interface{})
+	if len(tools) != 1 {
+		t.Fatalf("se esperaba una herramienta: %v", tools)
+	}
+	function, _ := tools[0].(map[string]interface{})["function"].(map[string]interface{})
+	if function["strict"] != true || !reflect.DeepEqual(function["parameters"], want) {
+		t.Errorf("herramienta inesperada: %v", function)
+	}
+}
+
+func TestOpenAIEmbed(t *testing.T) {
+	api := newOpenAIAPI(t, "{}")
+
+	embeddings, err := NewOpenAI(Config{URL: api.URL + "/v1"}).Embed(context.Background(), "emb", []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	if !reflect.DeepEqual(embeddings, [][]float32{{0.1, 0.2}, {0.3, 0.4}}) {
+		t.Errorf("embeddings = %v", embeddings)
+	}
+	body := api.last(t, "/v1/embeddings")
+	if body["model"] != "emb" || !reflect.DeepEqual(body["input"], []interface{}{"a", "b"}) {
+		t.Errorf("petición inesperada: %v", body)
+	}
+
+	_, err = NewOpenAI(Config{URL: api.URL + "/v1"}).Embed(context.Background(), "emb", []string{"a"})
+	if err == nil || !strings.Contains(err.Error(), "embeddings") {
+		t.Errorf("se esperaba error por la cantidad de embeddings, llegó %v", err)
+	}
+}
@@ -0,0 +1,179 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ivanneira/Lapislazuli/internal/logger"
+)
+
+// RetryPolicy define cuántas veces y con qué espera se reintenta una llamada.
+// La espera crece exponencialmente desde BaseDelay hasta MaxDelay, con jitter.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// permanentError marca un error que no debe reintentarse.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marca err para que no se reintente ni se pase a otro backend.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Retryable indica si err es un fallo transitorio del servidor: errores de
+// red, timeouts, HTTP 429 y 5xx, o el circuito abierto.
+func Retryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var permanent *permanentError
+	if errors.As(err, &permanent) {
+		return false
+	}
+	if errors.Is(err, ErrCircuitOpen) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// retryAfter devuelve la espera pedida por el header Retry-After de una
+// respuesta 429 o 503, en segundos o como fecha HTTP.
+func retryAfter(err error) (time.Duration, bool) {
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.Header == nil {
+		return 0, false
+	}
+	if statusErr.StatusCode != http.StatusTooManyRequests && statusErr.StatusCode != http.StatusServiceUnavailable {
+		return 0, false
+	}
+	value := statusErr.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
+
+// backoff devuelve la espera antes del reintento attempt (desde 1), con
+// jitter completo: un valor al azar entre 0 y la espera exponencial.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay << (attempt - 1)
+	if delay <= 0 || delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(delay) + 1))
+}
+
+// Do llama a fn hasta que termine sin un error reintentable o se agoten los
+// intentos. Un Retry-After mayor que MaxDelay corta los reintentos.
+func (p RetryPolicy) Do(ctx context.Context, fn func() error) error {
+	attempts := max(p.MaxAttempts, 1)
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil || !Retryable(err) || attempt >= attempts || errors.Is(err, ErrCircuitOpen) {
+			return err
+		}
+
+		delay := p.backoff(attempt)
+		if wait, ok := retryAfter(err); ok {
+			if wait > p.MaxDelay {
+				logger.Warn("El servidor pide esperar %v, más que el máximo de %v", wait, p.MaxDelay)
+				return err
+			}
+			delay = wait
+		}
+		logger.Warn("Fallo transitorio en la llamada al modelo (intento %d de %d), reintentando en %v: %v",
+			attempt, attempts, delay, err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// retrying reintenta las llamadas de otro Backend según una RetryPolicy.
+type retrying struct {
+	backend Backend
+	policy  RetryPolicy
+}
+
+// WithRetry envuelve backend para reintentar los fallos transitorios. Stream
+// solo se reintenta si todavía no se entregó ningún fragmento.
+func WithRetry(backend Backend, policy RetryPolicy) Backend {
+	return &retrying{backend: backend, policy: policy}
+}
+
+func (r *retrying) Chat(ctx context.Context, req ChatRequest) (resp *ChatResponse, err error) {
+	err = r.policy.Do(ctx, func() error {
+		resp, err = r.backend.Chat(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+func (r *retrying) Structured(ctx context.Context, req ChatRequest, schema Schema) (resp *ChatResponse, err error) {
+	err = r.policy.Do(ctx, func() error {
+		resp, err = r.backend.Structured(ctx, req, schema)
+		return err
+	})
+	return resp, err
+}
+
+func (r *retrying) Stream(ctx context.Context, req ChatRequest, onToken func(string)) (resp *ChatResponse, err error) {
+	emitted := false
+	err = r.policy.Do(ctx, func() error {
+		resp, err = r.backend.Stream(ctx, req, func(token string) {
+			emitted = true
+			if onToken != nil {
+				onToken(token)
+			}
+		})
+		if err != nil && emitted {
+			return Permanent(err)
+		}
+		return err
+	})
+	return resp, err
+}
+
+func (r *retrying) Embed(ctx context.Context, model string, input []string) (embeddings [][]float32, err error) {
+	err = r.policy.Do(ctx, func() error {
+		embeddings, err = r.backend.Embed(ctx, model, input)
+		return err
+	})
+	return embeddings, err
+}
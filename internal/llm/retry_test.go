@@ -0,0 +1,178 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// okChat es la respuesta de un chat que funcionó en la API de OpenAI.
+const okChat = `{"choices":[{"message":{"role":"assistant","content":"hola"}}]}`
+
+// flakyServer es un servidor de modelos falso que atiende la llamada n con
+// steps[n], o con el último paso si ya no quedan. Cuenta las llamadas.
+type flakyServer struct {
+	*httptest.Server
+	calls atomic.Int32
+}
+
+func newFlakyServer(t *testing.T, steps ...http.HandlerFunc) *flakyServer {
+	t.Helper()
+	s := &flakyServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(s.calls.Add(1)) - 1
+		steps[min(n, len(steps)-1)](w, r)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// status responde con code y, si retryAfter no está vacío, el header
+// Retry-After.
+func status(code int, retryAfter string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		http.Error(w, http.StatusText(code), code)
+	}
+}
+
+// reply responde 200 con body como JSON.
+func reply(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}
+}
+
+// drop corta la conexión sin responder.
+func drop(w http.ResponseWriter, r *http.Request) {
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		panic(err)
+	}
+	conn.Close()
+}
+
+// fastPolicy reintenta sin esperar salvo que el servidor lo pida.
+var fastPolicy = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Second}
+
+func chat(backend Backend) (*ChatResponse, error) {
+	return backend.Chat(context.Background(), ChatRequest{Model: "m", Messages: []Message{{Role: "user", Content: "hola"}}})
+}
+
+func TestRetryServiceUnavailableWithRetryAfter(t *testing.T) {
+	server := newFlakyServer(t, status(http.StatusServiceUnavailable, "1"), reply(okChat))
+	backend := WithRetry(NewOpenAI(Config{URL: server.URL}), fastPolicy)
+
+	start := time.Now()
+	resp, err := chat(backend)
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if resp.Content != "hola" {
+		t.Errorf("Content = %q, se esperaba hola", resp.Content)
+	}
+	if got := server.calls.Load(); got != 2 {
+		t.Errorf("se hicieron %d llamadas, se esperaban 2", got)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("se reintentó a los %v, antes del Retry-After", elapsed)
+	}
+}
+
+func TestRetryAfterAboveMaxDelayStops(t *testing.T) {
+	server := newFlakyServer(t, status(http.StatusServiceUnavailable, "60"), reply(okChat))
+	backend := WithRetry(NewOpenAI(Config{URL: server.URL}), fastPolicy)
+
+	_, err := chat(backend)
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("se esperaba el 503, llegó %v", err)
+	}
+	if got := server.calls.Load(); got != 1 {
+		t.Errorf("se hicieron %d llamadas, se esperaba 1", got)
+	}
+}
+
+func TestRetryTooManyRequests(t *testing.T) {
+	server := newFlakyServer(t, status(http.StatusTooManyRequests, ""), status(http.StatusTooManyRequests, ""), reply(okChat))
+	backend := WithRetry(NewOpenAI(Config{URL: server.URL}), fastPolicy)
+
+	if _, err := chat(backend); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if got := server.calls.Load(); got != 3 {
+		t.Errorf("se hicieron %d llamadas, se esperaban 3", got)
+	}
+}
+
+func TestRetryDroppedConnection(t *testing.T) {
+	server := newFlakyServer(t, drop, reply(okChat))
+	backend := WithRetry(NewOpenAI(Config{URL: server.URL, Client: &http.Client{}}), fastPolicy)
+
+	if _, err := chat(backend); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if got := server.calls.Load(); got != 2 {
+		t.Errorf("se hicieron %d llamadas, se esperaban 2", got)
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	server := newFlakyServer(t, status(http.StatusBadGateway, ""))
+	backend := WithRetry(NewOpenAI(Config{URL: server.URL}), fastPolicy)
+
+	if _, err := chat(backend); !Retryable(err) {
+		t.Fatalf("se esperaba un error transitorio, llegó %v", err)
+	}
+	if got := server.calls.Load(); got != 3 {
+		t.Errorf("se hicieron %d llamadas, se esperaban 3", got)
+	}
+}
+
+func TestNoRetryOnClientError(t *testing.T) {
+	for _, code := range []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound} {
+		server := newFlakyServer(t, status(code, ""), reply(okChat))
+		backend := WithRetry(NewOpenAI(Config{URL: server.URL}), fastPolicy)
+
+		_, err := chat(backend)
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) || statusErr.StatusCode != code {
+			t.Errorf("HTTP %d: se esperaba el error del servidor, llegó %v", code, err)
+		}
+		if got := server.calls.Load(); got != 1 {
+			t.Errorf("HTTP %d: se hicieron %d llamadas, se esperaba 1", code, got)
+		}
+	}
+}
+
+func TestRetryStopsOnCancel(t *testing.T) {
+	server := newFlakyServer(t, status(http.StatusServiceUnavailable, "1"))
+	backend := WithRetry(NewOpenAI(Config{URL: server.URL}), fastPolicy)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := backend.Chat(ctx, ChatRequest{Model: "m"}); err == nil {
+		t.Fatal("se esperaba error")
+	}
+	if got := server.calls.Load(); got != 1 {
+		t.Errorf("se hicieron %d llamadas, se esperaba 1", got)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt, limit := range map[int]time.Duration{1: 100 * time.Millisecond, 3: 400 * time.Millisecond, 10: time.Second} {
+		for i := 0; i < 50; i++ {
+			if delay := policy.backoff(attempt); delay < 0 || delay > limit {
+				t.Fatalf("backoff(%d) = %v, fuera de [0, %v]", attempt, delay, limit)
+			}
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package llm
+
+import "sort"
+
+// strictSchema adapta un JSON Schema a las reglas del modo strict de OpenAI:
+// todo objeto lleva additionalProperties: false y lista todas sus
+// propiedades en required (las opcionales pasan a aceptar null), const se
+// escribe como un enum de un valor y oneOf como anyOf. No modifica schema.
+func strictSchema(schema map[string]interface{}) map[string]interface{} {
+	if schema == nil {
+		return nil
+	}
+	strict := make(map[string]interface{}, len(schema))
+	for key, value := range schema {
+		strict[key] = value
+	}
+
+	if value, ok := strict["const"]; ok {
+		strict["enum"] = []interface{}{value}
+		delete(strict, "const")
+	}
+	if alternatives, ok := strict["oneOf"]; ok {
+		strict["anyOf"] = alternatives
+		delete(strict, "oneOf")
+	}
+	if alternatives, ok := strict["anyOf"].([]interface{}); ok {
+		converted := make([]interface{}, len(alternatives))
+		for i, alt := range alternatives {
+			converted[i] = alt
+			if altSchema, ok := alt.(map[string]interface{}); ok {
+				converted[i] = strictSchema(altSchema)
+			}
+		}
+		strict["anyOf"] = converted
+	}
+	if items, ok := strict["items"].(map[string]interface{}); ok {
+		strict["items"] = strictSchema(items)
+	}
+
+	properties, hasProperties := strict["properties"].(map[string]interface{})
+	if !hasProperties && strict["type"] != "object" {
+		return strict
+	}
+	required := make(map[string]bool)
+	for _, name := range names(strict["required"]) {
+		required[name] = true
+	}
+	converted := make(map[string]interface{}, len(properties))
+	all := make([]string, 0, len(properties))
+	for name, prop := range properties {
+		propSchema, _ := prop.(map[string]interface{})
+		propSchema = strictSchema(propSchema)
+		if !required[name] {
+			propSchema = nullable(propSchema)
+		}
+		converted[name] = propSchema
+		all = append(all, name)
+	}
+	sort.Strings(all)
+	strict["properties"] = converted
+	strict["required"] = all
+	strict["additionalProperties"] = false
+	return strict
+}
+
+// nullable devuelve schema aceptando además null.
+func nullable(schema map[string]interface{}) map[string]interface{} {
+	if schema == nil {
+		return map[string]interface{}{"type": "null"}
+	}
+	switch t := list(schema["type"]).(type) {
+	case string:
+		if t != "null" {
+			schema["type"] = []interface{}{t, "null"}
+		}
+	case []interface{}:
+		for _, v := range t {
+			if v == "null" {
+				return schema
+			}
+		}
+		schema["type"] = append(append([]interface{}{}, t...), "null")
+	default:
+		if alternatives, ok := schema["anyOf"].([]interface{}); ok {
+			schema["anyOf"] = append(append([]interface{}{}, alternatives...), map[string]interface{}{"type": "null"})
+			return schema
+		}
+	}
+	if enum, ok := list(schema["enum"]).([]interface{}); ok {
+		schema["enum"] = append(append([]interface{}{}, enum...), nil)
+	}
+	return schema
+}
+
+// list devuelve v como []interface{} si es un []string, que es como
+// quedan las listas de los esquemas armados en Go; si no, v tal cual.
+func list(v interface{}) interface{} {
+	if strs, ok := v.([]string); ok {
+		out := make([]interface{}, len(strs))
+		for i, s := range strs {
+			out[i] = s
+		}
+		return out
+	}
+	return v
+}
+
+// names convierte una lista de nombres de un esquema, que puede venir como
+// []string o como []interface{} si se decodificó de JSON.
+func names(v interface{}) []string {
+	switch items := v.(type) {
+	case []string:
+		return items
+	case []interface{}:
+		out := make([]string, 0, len(items))
+		for _, item := range items {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package llm
+
+// Tool es una función que el modelo puede pedir que se ejecute. Parameters
+// es el JSON Schema de sus argumentos.
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  map[string]interface{}
+}
+
+// ToolCall es una llamada a una herramienta pedida por el modelo, con la
+// forma de la API de OpenAI. Function.Arguments es el JSON de los argumentos.
+type ToolCall struct {
+	ID       string       `json:"id"`
+	Type     string       `json:"type"`
+	Function ToolFunction `json:"function"`
+}
+
+// ToolFunction es la función y los argumentos de una ToolCall.
+type ToolFunction struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// openAITool es una herramienta en el formato de OpenAI, que también usa
+// Ollama.
+type openAITool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string                 `json:"name"`
+		Description string                 `json:"description,omitempty"`
+		Parameters  map[string]interface{} `json:"parameters"`
+		Strict      bool                   `json:"strict,omitempty"`
+	} `json:"function"`
+}
+
+// openAITools convierte las herramientas. Con strict se piden en modo strict
+// y sus esquemas se adaptan a ese modo.
+func openAITools(tools []Tool, strict bool) []openAITool {
+	if len(tools) == 0 {
+		return nil
+	}
+	converted := make([]openAITool, len(tools))
+	for i, t := range tools {
+		converted[i].Type = "function"
+		converted[i].Function.Name = t.Name
+		converted[i].Function.Description = t.Description
+		converted[i].Function.Parameters = t.Parameters
+		if converted[i].Function.Parameters == nil {
+			converted[i].Function.Parameters = map[string]interface{}{"type": "object"}
+		}
+		if strict {
+			converted[i].Function.Strict = true
+			converted[i].Function.Parameters = strictSchema(converted[i].Function.Parameters)
+		}
+	}
+	return converted
+}
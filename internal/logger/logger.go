@@ -3,6 +3,7 @@ package logger
 import (
 	"bytes"
 	"encoding/json"
+	"io"
 	"log"
 	"os"
 	"sync"
@@ -65,6 +66,10 @@ var levelFuncs = map[Level]LogFunc{
 func SetEnabled(e bool) { enabled = e }
 func SetLevel(l Level)  { level = l }
 
+// SetOutput cambia a dónde se escriben los logs, por ejemplo a stderr cuando
+// stdout se usa para otra cosa.
+func SetOutput(w io.Writer) { logger.SetOutput(w) }
+
 func Log(lvl Level, format string, v ...interface{}) {
 	if fn, ok := levelFuncs[lvl]; ok {
 		fn(format, v...)
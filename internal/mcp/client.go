@@ -1,49 +1,63 @@
 package mcp
 
 import (
+	"context"
+	"errors"
+
 	"github.com/ivanneira/Lapislazuli/internal/logger"
 )
 
-// AIClient proporciona una interfaz simple para aplicaciones
+// AIClient proporciona una interfaz simple para aplicaciones. Las sesiones
+// se cargan y guardan a través del propio AIService.
 type AIClient struct {
-	service    AIService
-	sessionMgr *SessionManager
+	service AIService
 }
 
 func NewAIClient(service AIService) *AIClient {
 	return &AIClient{
-		service:    service,
-		sessionMgr: NewSessionManager(),
+		service: service,
 	}
 }
 
-func (c *AIClient) Process(request AIRequest) AIResponse {
+// Process procesa la petición dentro del contexto de su sesión. ctx controla
+// la cancelación del procesamiento.
+func (c *AIClient) Process(ctx context.Context, request AIRequest) AIResponse {
 	logger.Info("Processing request for session: %s", request.SessionID)
 	logger.JSON("Request", request)
 
-	ctx := c.sessionMgr.GetContext(request.SessionID)
+	mctx, err := c.service.LoadContext(request.SessionID)
+	if err != nil {
+		logger.Error("Error loading context: %v", err)
+		return AIResponse{Error: err}
+	}
 	logger.Debug("Retrieved context for session: %s", request.SessionID)
 
 	// Aplicar propiedades al contexto
 	for k, v := range request.Properties {
-		ctx.SetProperty(k, v)
+		mctx.SetProperty(k, v)
 	}
 
-	output, err := c.service.Process(ctx, request.Input)
-	if err != nil {
+	output, err := c.service.Process(ctx, mctx, request.Input)
+	switch {
+	case errors.Is(err, context.Canceled):
+		logger.Info("Request canceled by client for session: %s", request.SessionID)
+	case err != nil:
 		logger.Error("Processing error: %v", err)
-	} else {
+	default:
 		logger.Debug("Processing successful")
 		logger.JSON("Output", output)
 	}
 
 	// Guardar contexto actualizado
-	c.sessionMgr.SaveContext(ctx)
-	logger.Debug("Context saved for session: %s", request.SessionID)
+	if saveErr := c.service.SaveContext(mctx); saveErr != nil {
+		logger.Error("Error saving context: %v", saveErr)
+	} else {
+		logger.Debug("Context saved for session: %s", request.SessionID)
+	}
 
 	response := AIResponse{
 		Output:  output,
-		Context: ctx,
+		Context: mctx,
 		Error:   err,
 	}
 	logger.JSON("Response", response)
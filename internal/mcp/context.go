@@ -13,18 +13,34 @@ var messagePool = sync.Pool{
 	},
 }
 
+// Roles de los mensajes guardados en el contexto.
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	// RoleAction marca el resultado de ejecutar una acción.
+	RoleAction = "action"
+	// RoleSummary marca el resumen de los mensajes más viejos de la sesión.
+	RoleSummary = "summary"
+	// RoleToolCall marca una llamada a una herramienta pedida por el agente,
+	// guardada como JSON.
+	RoleToolCall = "tool_call"
+	// RoleTool marca el resultado de una herramienta.
+	RoleTool = "tool"
+)
+
 type ContextMetadata struct {
-	SessionID   string
-	Created     time.Time
-	LastUpdated time.Time
-	Properties  map[string]interface{}
+	SessionID   string                 `json:"session_id"`
+	Created     time.Time              `json:"created"`
+	LastUpdated time.Time              `json:"last_updated"`
+	Properties  map[string]interface{} `json:"properties"`
 }
 
 // Optimizar estructura Message para mejor alineación en memoria
 type Message struct {
-	Timestamp time.Time
-	Role      string
-	Content   string
+	Timestamp time.Time `json:"timestamp"`
+	Role      string    `json:"role"`
+	Content   string    `json:"content"`
 }
 
 type ModelContext interface {
@@ -33,6 +49,7 @@ type ModelContext interface {
 	GetMetadata() ContextMetadata
 	SetProperty(key string, value interface{})
 	GetProperty(key string) interface{}
+	Compact(n int, summary string)
 	Clear()
 }
 
@@ -117,6 +134,28 @@ func (c *Context) GetProperty(key string) interface{} {
 	return c.metadata.Properties[key]
 }
 
+// Compact reemplaza los n mensajes más viejos por un único mensaje con el
+// resumen, con rol RoleSummary.
+func (c *Context) Compact(n int, summary string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if n <= 0 {
+		return
+	}
+	if n > len(c.messages) {
+		n = len(c.messages)
+	}
+	compacted := Message{
+		Timestamp: c.messages[n-1].Timestamp,
+		Role:      RoleSummary,
+		Content:   summary,
+	}
+	c.messages = append([]Message{compacted}, c.messages[n:]...)
+	c.metadata.LastUpdated = time.Now()
+	logger.Debug("Contexto compactado: %d mensajes resumidos", n)
+}
+
 func (c *Context) Clear() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
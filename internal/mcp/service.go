@@ -1,13 +1,17 @@
 package mcp
 
 import (
+	"context"
 	"sync"
+	"time"
 
 	lru "github.com/hashicorp/golang-lru"
+
+	"github.com/ivanneira/Lapislazuli/internal/logger"
 )
 
 type AIService interface {
-	Process(ctx ModelContext, input string) (string, error)
+	Process(ctx context.Context, mctx ModelContext, input string) (string, error)
 	LoadContext(sessionID string) (ModelContext, error)
 	SaveContext(ctx ModelContext) error
 }
@@ -24,49 +28,118 @@ type AIResponse struct {
 	Error   error
 }
 
-// SessionManager maneja las sesiones de contexto
+// SessionManager maneja las sesiones de contexto sobre un SessionStore, con
+// un LRU delante para las sesiones frecuentes. Las sesiones sin actividad
+// durante más de ttl se consideran vencidas.
 type SessionManager struct {
-	sync.RWMutex
-	contexts map[string]ModelContext
+	sync.Mutex
+	store SessionStore
+	ttl   time.Duration
 	// Agregar LRU cache para sesiones frecuentes
 	cache *lru.Cache
+	stop  chan struct{}
 }
 
-func NewSessionManager() *SessionManager {
+// NewSessionManager crea un SessionManager sobre store. Con ttl 0 las
+// sesiones no vencen.
+func NewSessionManager(store SessionStore, ttl time.Duration) *SessionManager {
 	cache, _ := lru.New(1000) // Cachear hasta 1000 sesiones
 	return &SessionManager{
-		contexts: make(map[string]ModelContext),
-		cache:    cache,
+		store: store,
+		ttl:   ttl,
+		cache: cache,
 	}
 }
 
-func (sm *SessionManager) GetContext(sessionID string) ModelContext {
-	sm.RLock()
+func (sm *SessionManager) expired(ctx ModelContext) bool {
+	return sm.ttl > 0 && time.Since(ctx.GetMetadata().LastUpdated) > sm.ttl
+}
+
+// GetContext devuelve el contexto de la sesión, creando uno nuevo si no
+// existe o si venció.
+func (sm *SessionManager) GetContext(sessionID string) (ModelContext, error) {
+	sm.Lock()
+	defer sm.Unlock()
+
 	// Intentar obtener del cache primero
-	if ctx, ok := sm.cache.Get(sessionID); ok {
-		sm.RUnlock()
-		return ctx.(ModelContext)
+	if ctx, ok := sm.cache.Get(sessionID); ok && !sm.expired(ctx.(ModelContext)) {
+		return ctx.(ModelContext), nil
 	}
 
-	if ctx, exists := sm.contexts[sessionID]; exists {
-		sm.cache.Add(sessionID, ctx)
-		sm.RUnlock()
-		return ctx
+	ctx, err := sm.store.Load(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if ctx == nil || sm.expired(ctx) {
+		ctx = NewContext(sessionID)
 	}
-	sm.RUnlock()
+	sm.cache.Add(sessionID, ctx)
+	return ctx, nil
+}
 
-	// Si no existe, crear nuevo con write lock
+// SaveContext guarda el contexto en el cache y en el store.
+func (sm *SessionManager) SaveContext(ctx ModelContext) error {
 	sm.Lock()
 	defer sm.Unlock()
-	ctx := NewContext(sessionID)
-	sm.contexts[sessionID] = ctx
-	sm.cache.Add(sessionID, ctx)
-	return ctx
+	sm.cache.Add(ctx.GetMetadata().SessionID, ctx)
+	return sm.store.Save(ctx)
+}
+
+// StartJanitor elimina periódicamente las sesiones vencidas del store y del
+// cache. No hace nada si el SessionManager no tiene ttl.
+func (sm *SessionManager) StartJanitor(interval time.Duration) {
+	if sm.ttl <= 0 || interval <= 0 {
+		return
+	}
+	sm.Lock()
+	if sm.stop != nil {
+		sm.Unlock()
+		return
+	}
+	sm.stop = make(chan struct{})
+	stop := sm.stop
+	sm.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				sm.expire()
+			case <-stop:
+				return
+			}
+		}
+	}()
 }
 
-func (sm *SessionManager) SaveContext(ctx ModelContext) {
+func (sm *SessionManager) expire() {
 	sm.Lock()
 	defer sm.Unlock()
-	sm.contexts[ctx.GetMetadata().SessionID] = ctx
-	sm.cache.Add(ctx.GetMetadata().SessionID, ctx)
+
+	for _, key := range sm.cache.Keys() {
+		if ctx, ok := sm.cache.Peek(key); ok && sm.expired(ctx.(ModelContext)) {
+			sm.cache.Remove(key)
+		}
+	}
+	removed, err := sm.store.Expire(time.Now().Add(-sm.ttl))
+	if err != nil {
+		logger.Error("Error eliminando sesiones vencidas: %v", err)
+		return
+	}
+	if removed > 0 {
+		logger.Info("Sesiones vencidas eliminadas: %d", removed)
+	}
+}
+
+// Close detiene el janitor y cierra el store.
+func (sm *SessionManager) Close() error {
+	sm.Lock()
+	if sm.stop != nil {
+		close(sm.stop)
+		sm.stop = nil
+	}
+	sm.Unlock()
+	return sm.store.Close()
 }
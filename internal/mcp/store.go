@@ -0,0 +1,234 @@
+package mcp
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SessionStore persiste los contextos de sesión.
+type SessionStore interface {
+	// Load devuelve el contexto guardado, o nil si la sesión no existe.
+	Load(sessionID string) (ModelContext, error)
+	Save(ctx ModelContext) error
+	Delete(sessionID string) error
+	// Expire elimina las sesiones sin actividad desde before y devuelve cuántas borró.
+	Expire(before time.Time) (int, error)
+	Close() error
+}
+
+// Tipos de SessionStore disponibles en OpenStore.
+const (
+	StoreMemory = "memory"
+	StoreFile   = "file"
+	StoreBolt   = "bolt"
+)
+
+// Rutas por defecto de cada SessionStore, distintas para que cambiar de uno a
+// otro no choque con lo que dejó el anterior.
+const (
+	DefaultFilePath = "sessions"
+	DefaultBoltPath = "sessions.db"
+)
+
+// OpenStore crea el SessionStore indicado. path es el directorio para "file"
+// y el archivo de base de datos para "bolt"; no se usa en "memory". Si path
+// está vacío se usa la ruta por defecto del store.
+func OpenStore(kind, path string) (SessionStore, error) {
+	switch kind {
+	case "", StoreMemory:
+		return NewMemoryStore(), nil
+	case StoreFile:
+		if path == "" {
+			path = DefaultFilePath
+		}
+		return NewFileStore(path)
+	case StoreBolt:
+		if path == "" {
+			path = DefaultBoltPath
+		}
+		return NewBoltStore(path)
+	default:
+		return nil, fmt.Errorf("tipo de session store desconocido: %s", kind)
+	}
+}
+
+// contextSnapshot es la forma serializada de un contexto.
+type contextSnapshot struct {
+	Metadata ContextMetadata `json:"metadata"`
+	Messages []Message       `json:"messages"`
+}
+
+func snapshotOf(ctx ModelContext) contextSnapshot {
+	return contextSnapshot{
+		Metadata: ctx.GetMetadata(),
+		Messages: ctx.GetMessages(),
+	}
+}
+
+func (s contextSnapshot) restore() *Context {
+	if s.Metadata.Properties == nil {
+		s.Metadata.Properties = make(map[string]interface{})
+	}
+	if s.Messages == nil {
+		s.Messages = make([]Message, 0)
+	}
+	return &Context{
+		messages: s.Messages,
+		metadata: s.Metadata,
+	}
+}
+
+func encodeContext(ctx ModelContext) ([]byte, error) {
+	return json.Marshal(snapshotOf(ctx))
+}
+
+func decodeContext(data []byte) (*Context, error) {
+	var s contextSnapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	return s.restore(), nil
+}
+
+// MemoryStore guarda las sesiones en memoria. Se pierden al reiniciar.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	contexts map[string]ModelContext
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{contexts: make(map[string]ModelContext)}
+}
+
+func (s *MemoryStore) Load(sessionID string) (ModelContext, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if ctx, ok := s.contexts[sessionID]; ok {
+		return ctx, nil
+	}
+	return nil, nil
+}
+
+func (s *MemoryStore) Save(ctx ModelContext) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.contexts[ctx.GetMetadata().SessionID] = ctx
+	return nil
+}
+
+func (s *MemoryStore) Delete(sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.contexts, sessionID)
+	return nil
+}
+
+func (s *MemoryStore) Expire(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	removed := 0
+	for id, ctx := range s.contexts {
+		if ctx.GetMetadata().LastUpdated.Before(before) {
+			delete(s.contexts, id)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func (s *MemoryStore) Close() error { return nil }
+
+// FileStore guarda cada sesión como un archivo JSON en un directorio. La
+// fecha de modificación del archivo se usa como última actividad.
+type FileStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+func NewFileStore(dir string) (*FileStore, error) {
+	if dir == "" {
+		return nil, errors.New("el file store necesita un directorio")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// path codifica el ID en hexadecimal para que no pueda salir del directorio.
+func (s *FileStore) path(sessionID string) string {
+	return filepath.Join(s.dir, hex.EncodeToString([]byte(sessionID))+".json")
+}
+
+func (s *FileStore) Load(sessionID string) (ModelContext, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := os.ReadFile(s.path(sessionID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeContext(data)
+}
+
+// Save escribe a un archivo temporal y lo renombra, para no dejar sesiones a
+// medio escribir.
+func (s *FileStore) Save(ctx ModelContext) error {
+	data, err := encodeContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path := s.path(ctx.GetMetadata().SessionID)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *FileStore) Delete(sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := os.Remove(s.path(sessionID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *FileStore) Expire(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(before) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.dir, entry.Name())); err == nil {
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func (s *FileStore) Close() error { return nil }
@@ -0,0 +1,97 @@
+package mcp
+
+import (
+	"errors"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var sessionsBucket = []byte("sessions")
+
+// BoltStore guarda las sesiones en una base BoltDB embebida, en un único archivo.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+func NewBoltStore(path string) (*BoltStore, error) {
+	if path == "" {
+		return nil, errors.New("el bolt store necesita la ruta del archivo")
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(sessionsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+func (s *BoltStore) Load(sessionID string) (ModelContext, error) {
+	var ctx *Context
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(sessionsBucket).Get([]byte(sessionID))
+		if data == nil {
+			return nil
+		}
+		var err error
+		ctx, err = decodeContext(data)
+		return err
+	})
+	if err != nil || ctx == nil {
+		return nil, err
+	}
+	return ctx, nil
+}
+
+func (s *BoltStore) Save(ctx ModelContext) error {
+	data, err := encodeContext(ctx)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).Put([]byte(ctx.GetMetadata().SessionID), data)
+	})
+}
+
+func (s *BoltStore) Delete(sessionID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).Delete([]byte(sessionID))
+	})
+}
+
+func (s *BoltStore) Expire(before time.Time) (int, error) {
+	removed := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(sessionsBucket)
+		expired := make([][]byte, 0)
+		err := bucket.ForEach(func(k, v []byte) error {
+			ctx, err := decodeContext(v)
+			if err != nil || ctx.metadata.LastUpdated.Before(before) {
+				expired = append(expired, append([]byte{}, k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+			removed++
+		}
+		return nil
+	})
+	return removed, err
+}
+
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
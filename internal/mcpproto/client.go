@@ -0,0 +1,156 @@
+package mcpproto
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+)
+
+// transport mueve mensajes entre el cliente y un servidor MCP.
+type transport interface {
+	// send envía req y espera su respuesta. Con una notificación no espera
+	// nada y devuelve nil.
+	send(ctx context.Context, req *Request) (*Response, error)
+	// negotiated informa la versión del protocolo acordada en initialize.
+	negotiated(version string)
+	close() error
+}
+
+// message es cualquier mensaje JSON-RPC: petición, notificación o respuesta.
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+func (m *message) response() *Response {
+	return &Response{JSONRPC: m.JSONRPC, ID: m.ID, Result: m.Result, Error: m.Error}
+}
+
+// Client habla con un servidor MCP externo para usar sus herramientas. Es
+// seguro usarlo desde varias goroutines.
+type Client struct {
+	transport transport
+	nextID    atomic.Int64
+}
+
+// Initialize hace el handshake con el servidor presentándose como info y
+// devuelve lo que el servidor informa de sí mismo. Hay que llamarlo antes
+// que a cualquier otro método.
+func (c *Client) Initialize(ctx context.Context, info Implementation) (*InitializeResult, error) {
+	var result InitializeResult
+	err := c.call(ctx, "initialize", &InitializeParams{
+		ProtocolVersion: LatestVersion,
+		Capabilities:    map[string]interface{}{},
+		ClientInfo:      info,
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+	if !supportedVersion(result.ProtocolVersion) {
+		return nil, fmt.Errorf("el servidor MCP usa la versión %q del protocolo, que no se soporta", result.ProtocolVersion)
+	}
+	c.transport.negotiated(result.ProtocolVersion)
+
+	if err := c.notify(ctx, "notifications/initialized"); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListTools devuelve todas las herramientas del servidor, recorriendo las
+// páginas que haga falta.
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	var tools []Tool
+	cursor := ""
+	for {
+		var params interface{}
+		if cursor != "" {
+			params = map[string]string{"cursor": cursor}
+		}
+		var result ListToolsResult
+		if err := c.call(ctx, "tools/list", params, &result); err != nil {
+			return nil, err
+		}
+		tools = append(tools, result.Tools...)
+		if result.NextCursor == "" || result.NextCursor == cursor {
+			return tools, nil
+		}
+		cursor = result.NextCursor
+	}
+}
+
+// CallTool ejecuta la herramienta name con args. Si la herramienta se
+// ejecutó pero falló, el resultado viene con IsError y sin error.
+func (c *Client) CallTool(ctx context.Context, name string, args map[string]interface{}) (*CallToolResult, error) {
+	var result CallToolResult
+	if err := c.call(ctx, "tools/call", &CallToolParams{Name: name, Arguments: args}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Close cierra la conexión y, si el servidor es un proceso, lo termina.
+func (c *Client) Close() error {
+	return c.transport.close()
+}
+
+func (c *Client) call(ctx context.Context, method string, params, result interface{}) error {
+	req, err := newRequest(strconv.FormatInt(c.nextID.Add(1), 10), method, params)
+	if err != nil {
+		return err
+	}
+	resp, err := c.transport.send(ctx, req)
+	if err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if result == nil || len(resp.Result) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(resp.Result, result); err != nil {
+		return fmt.Errorf("respuesta inválida del servidor MCP a %s: %v", method, err)
+	}
+	return nil
+}
+
+func (c *Client) notify(ctx context.Context, method string) error {
+	req, err := newRequest("", method, nil)
+	if err != nil {
+		return err
+	}
+	_, err = c.transport.send(ctx, req)
+	return err
+}
+
+// newRequest arma una petición, o una notificación si id es vacío.
+func newRequest(id, method string, params interface{}) (*Request, error) {
+	req := &Request{JSONRPC: "2.0", Method: method}
+	if id != "" {
+		req.ID = json.RawMessage(id)
+	}
+	if params != nil {
+		encoded, err := json.Marshal(params)
+		if err != nil {
+			return nil, err
+		}
+		req.Params = encoded
+	}
+	return req, nil
+}
+
+func supportedVersion(version string) bool {
+	for _, v := range SupportedVersions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,165 @@
+package mcpproto
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Headers del transporte "streamable HTTP".
+const (
+	sessionHeader = "Mcp-Session-Id"
+	versionHeader = "MCP-Protocol-Version"
+)
+
+// httpTransport habla con un servidor MCP por "streamable HTTP": cada
+// mensaje es un POST y la respuesta llega como JSON o como un stream de
+// eventos SSE. Si el servidor asigna una sesión en initialize, se envía en
+// las peticiones siguientes.
+type httpTransport struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+
+	mu      sync.Mutex
+	session string
+	version string
+}
+
+// NewHTTPClient habla con el servidor MCP de url. headers se agregan a cada
+// petición, por ejemplo para autenticarse.
+func NewHTTPClient(url string, headers map[string]string) *Client {
+	return &Client{transport: &httpTransport{url: url, headers: headers, client: &http.Client{}}}
+}
+
+func (t *httpTransport) send(ctx context.Context, req *Request) (*Response, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "application/json, text/event-stream")
+	t.setHeaders(httpReq)
+
+	resp, err := t.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo conectar con el servidor MCP: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if session := resp.Header.Get(sessionHeader); session != "" {
+		t.mu.Lock()
+		t.session = session
+		t.mu.Unlock()
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("el servidor MCP respondió %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	if req.IsNotification() {
+		return nil, nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "text/event-stream" {
+		return readEventResponse(resp.Body, req.ID)
+	}
+	var msg message
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxMessageSize)).Decode(&msg); err != nil {
+		return nil, fmt.Errorf("respuesta inválida del servidor MCP: %v", err)
+	}
+	return msg.response(), nil
+}
+
+func (t *httpTransport) setHeaders(req *http.Request) {
+	for key, value := range t.headers {
+		req.Header.Set(key, value)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.session != "" {
+		req.Header.Set(sessionHeader, t.session)
+	}
+	if t.version != "" {
+		req.Header.Set(versionHeader, t.version)
+	}
+}
+
+func (t *httpTransport) negotiated(version string) {
+	t.mu.Lock()
+	t.version = version
+	t.mu.Unlock()
+}
+
+// close termina la sesión en el servidor, si la hay. Si el servidor no lo
+// permite no pasa nada: la sesión vence sola.
+func (t *httpTransport) close() error {
+	t.mu.Lock()
+	session := t.session
+	t.mu.Unlock()
+	if session == "" {
+		return nil
+	}
+	req, err := http.NewRequest(http.MethodDelete, t.url, nil)
+	if err != nil {
+		return err
+	}
+	t.setHeaders(req)
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// readEventResponse lee eventos SSE hasta encontrar la respuesta con el ID
+// id. Las peticiones y notificaciones que el servidor mande en el stream se
+// ignoran.
+func readEventResponse(r io.Reader, id json.RawMessage) (*Response, error) {
+	var data []byte
+	match := func() *Response {
+		var msg message
+		if err := json.Unmarshal(data, &msg); err == nil && msg.Method == "" && bytes.Equal(msg.ID, id) {
+			return msg.response()
+		}
+		data = data[:0]
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if value, ok := bytes.CutPrefix(line, []byte("data:")); ok {
+			data = append(data, bytes.TrimPrefix(value, []byte(" "))...)
+			data = append(data, '\n')
+			continue
+		}
+		if len(line) == 0 && len(data) > 0 {
+			if resp := match(); resp != nil {
+				return resp, nil
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if resp := match(); resp != nil {
+			return resp, nil
+		}
+	}
+	return nil, fmt.Errorf("el servidor MCP cerró el stream sin responder")
+}
@@ -0,0 +1,189 @@
+package mcpproto
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/ivanneira/Lapislazuli/internal/logger"
+)
+
+// closeTimeout es cuánto se espera a que el proceso del servidor termine
+// después de cerrarle stdin antes de matarlo.
+const closeTimeout = 2 * time.Second
+
+// errServerClosed indica que el proceso del servidor MCP terminó.
+var errServerClosed = errors.New("el servidor MCP terminó")
+
+// stdioTransport habla con un servidor MCP lanzado como proceso: un mensaje
+// JSON-RPC por línea en su stdin y en su stdout. Lo que escriba en stderr va
+// al stderr propio.
+type stdioTransport struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	pending map[string]chan *Response
+	done    chan struct{}
+	exited  chan struct{}
+}
+
+// NewStdioClient lanza command con args como servidor MCP y habla con él por
+// stdio. El proceso hereda el entorno propio y vive hasta Close.
+func NewStdioClient(command string, args []string) (*Client, error) {
+	cmd := exec.Command(command, args...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("no se pudo lanzar el servidor MCP %s: %v", command, err)
+	}
+
+	return &Client{transport: newStdioTransport(cmd, stdin, stdout)}, nil
+}
+
+// newStdioTransport habla por stdin y stdout con el servidor. cmd es su
+// proceso, o nil si el servidor corre en el mismo proceso: entonces se da
+// por terminado cuando stdout se cierra.
+func newStdioTransport(cmd *exec.Cmd, stdin io.WriteCloser, stdout io.Reader) *stdioTransport {
+	t := &stdioTransport{
+		cmd:     cmd,
+		stdin:   stdin,
+		pending: make(map[string]chan *Response),
+		done:    make(chan struct{}),
+		exited:  make(chan struct{}),
+	}
+	go t.read(stdout)
+	return t
+}
+
+// read reparte las respuestas del servidor a quienes las esperan y contesta
+// sus peticiones. Cuando stdout se cierra, las llamadas pendientes fallan.
+func (t *stdioTransport) read(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+	for scanner.Scan() {
+		var msg message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			logger.Warn("Mensaje inválido del servidor MCP: %s", scanner.Bytes())
+			continue
+		}
+		if msg.Method != "" {
+			t.answer(&msg)
+			continue
+		}
+
+		t.mu.Lock()
+		ch, ok := t.pending[string(msg.ID)]
+		delete(t.pending, string(msg.ID))
+		t.mu.Unlock()
+		if ok {
+			ch <- msg.response()
+		}
+	}
+	close(t.done)
+	if t.cmd != nil {
+		t.cmd.Wait()
+	}
+	close(t.exited)
+}
+
+// answer contesta las peticiones que hace el servidor. Solo se soporta
+// ping; las notificaciones se ignoran.
+func (t *stdioTransport) answer(msg *message) {
+	if len(msg.ID) == 0 {
+		logger.Debug("Notificación del servidor MCP: %s", msg.Method)
+		return
+	}
+	resp := &Response{JSONRPC: "2.0", ID: msg.ID, Result: json.RawMessage("{}")}
+	if msg.Method != "ping" {
+		resp = errorResponse(msg.ID, &Error{Code: CodeMethodNotFound, Message: fmt.Sprintf("método desconocido: %s", msg.Method)})
+	}
+	if err := t.write(resp); err != nil {
+		logger.Warn("No se pudo responder al servidor MCP: %v", err)
+	}
+}
+
+func (t *stdioTransport) send(ctx context.Context, req *Request) (*Response, error) {
+	if req.IsNotification() {
+		return nil, t.write(req)
+	}
+
+	ch := make(chan *Response, 1)
+	t.mu.Lock()
+	t.pending[string(req.ID)] = ch
+	t.mu.Unlock()
+	defer func() {
+		t.mu.Lock()
+		delete(t.pending, string(req.ID))
+		t.mu.Unlock()
+	}()
+
+	if err := t.write(req); err != nil {
+		return nil, err
+	}
+	select {
+	case resp := <-ch:
+		return resp, nil
+	case <-t.done:
+		// La respuesta pudo llegar justo antes de que se cerrara stdout
+		select {
+		case resp := <-ch:
+			return resp, nil
+		default:
+			return nil, errServerClosed
+		}
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (t *stdioTransport) write(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	select {
+	case <-t.done:
+		return errServerClosed
+	default:
+	}
+	if _, err := t.stdin.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("no se pudo escribir al servidor MCP: %v", err)
+	}
+	return nil
+}
+
+func (t *stdioTransport) negotiated(string) {}
+
+// close cierra stdin para que el servidor termine y, si no lo hace a tiempo,
+// mata el proceso.
+func (t *stdioTransport) close() error {
+	t.stdin.Close()
+	select {
+	case <-t.exited:
+	case <-time.After(closeTimeout):
+		if t.cmd == nil {
+			return fmt.Errorf("el servidor MCP no cerró la conexión")
+		}
+		t.cmd.Process.Kill()
+		<-t.exited
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package mcpproto
+
+import (
+	"crypto/subtle"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// HTTPHandler atiende el transporte "streamable HTTP" en un único endpoint:
+// cada POST trae un mensaje (o un lote) y la respuesta vuelve como JSON en
+// el cuerpo, o 202 sin cuerpo si eran solo notificaciones. El servidor no
+// envía mensajes por su cuenta, así que GET responde 405. Las peticiones con
+// un header Origin que no esté en allowedOrigins se rechazan, para evitar
+// ataques de DNS rebinding desde navegadores. Si token no está vacío, cada
+// petición tiene que traerlo como "Authorization: Bearer <token>"; si no,
+// responde 401.
+func HTTPHandler(server *Server, allowedOrigins []string, token string) http.Handler {
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		allowed[origin] = true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if origin := r.Header.Get("Origin"); origin != "" && !allowed[origin] {
+			http.Error(w, "origen no permitido", http.StatusForbidden)
+			return
+		}
+		if token != "" && !validToken(r.Header.Get("Authorization"), token) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "token inválido", http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "método no soportado", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxMessageSize))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp := server.Handle(r.Context(), body)
+		if resp == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(resp)
+	})
+}
+
+// validToken compara en tiempo constante el token Bearer del header con el
+// esperado.
+func validToken(header, token string) bool {
+	got, ok := strings.CutPrefix(header, "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
@@ -0,0 +1,125 @@
+package mcpproto
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testToken = "s3cret"
+
+func newTestHTTPServer(t *testing.T, token string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(HTTPHandler(newTestServer(), []string{"http://localhost:8080"}, token))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// post envía un mensaje al endpoint con los headers indicados.
+func post(t *testing.T, url, body string, headers map[string]string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+const pingMessage = `{"jsonrpc":"2.0","id":1,"method":"ping"}`
+
+func TestHTTPHandler(t *testing.T) {
+	server := newTestHTTPServer(t, testToken)
+	client := NewHTTPClient(server.URL, map[string]string{"Authorization": "Bearer " + testToken})
+	exercise(t, client)
+	if err := client.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+}
+
+func TestHTTPHandlerWithoutToken(t *testing.T) {
+	server := newTestHTTPServer(t, "")
+	exercise(t, NewHTTPClient(server.URL, nil))
+}
+
+func TestHTTPHandlerRejectsBadToken(t *testing.T) {
+	server := newTestHTTPServer(t, testToken)
+
+	for name, header := range map[string]string{
+		"sin token":        "",
+		"token incorrecto": "Bearer otro",
+		"sin Bearer":       testToken,
+	} {
+		resp := post(t, server.URL, pingMessage, map[string]string{"Authorization": header})
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("%s: estado %d, se esperaba 401", name, resp.StatusCode)
+		}
+		if resp.Header.Get("WWW-Authenticate") != "Bearer" {
+			t.Errorf("%s: falta WWW-Authenticate", name)
+		}
+	}
+
+	_, err := NewHTTPClient(server.URL, nil).Initialize(context.Background(), Implementation{Name: "cliente"})
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("el cliente sin token debería recibir 401, llegó %v", err)
+	}
+}
+
+func TestHTTPHandlerOrigin(t *testing.T) {
+	server := newTestHTTPServer(t, "")
+
+	resp := post(t, server.URL, pingMessage, map[string]string{"Origin": "http://evil.example"})
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("origen no permitido: estado %d, se esperaba 403", resp.StatusCode)
+	}
+	resp = post(t, server.URL, pingMessage, map[string]string{"Origin": "http://localhost:8080"})
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("origen permitido: estado %d, se esperaba 200", resp.StatusCode)
+	}
+
+	_, err := NewHTTPClient(server.URL, map[string]string{"Origin": "http://evil.example"}).
+		Initialize(context.Background(), Implementation{Name: "cliente"})
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("el cliente con otro origen debería recibir 403, llegó %v", err)
+	}
+}
+
+func TestHTTPHandlerOriginBeforeToken(t *testing.T) {
+	server := newTestHTTPServer(t, testToken)
+
+	resp := post(t, server.URL, pingMessage, map[string]string{
+		"Origin":        "http://evil.example",
+		"Authorization": "Bearer " + testToken,
+	})
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("estado %d, se esperaba 403 aunque el token sea válido", resp.StatusCode)
+	}
+}
+
+func TestHTTPHandlerNotificationAndMethods(t *testing.T) {
+	server := newTestHTTPServer(t, "")
+
+	resp := post(t, server.URL, `{"jsonrpc":"2.0","method":"notifications/initialized"}`, nil)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("notificación: estado %d, se esperaba 202", resp.StatusCode)
+	}
+
+	get, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	get.Body.Close()
+	if get.StatusCode != http.StatusMethodNotAllowed || get.Header.Get("Allow") != http.MethodPost {
+		t.Errorf("GET: estado %d, se esperaba 405 con Allow: POST", get.StatusCode)
+	}
+}
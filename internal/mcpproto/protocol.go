@@ -0,0 +1,130 @@
+// Package mcpproto implementa el Model Context Protocol (JSON-RPC 2.0) para
+// exponer las acciones como herramientas a agentes externos: el servidor,
+// independiente del transporte, y los transportes stdio y HTTP
+// ("streamable HTTP"). También tiene el cliente, para usar como acciones las
+// herramientas de servidores MCP externos.
+package mcpproto
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// LatestVersion es la versión del protocolo que se ofrece si el cliente pide
+// una que no se soporta.
+const LatestVersion = "2025-06-18"
+
+// SupportedVersions son las versiones del protocolo que el servidor acepta.
+var SupportedVersions = []string{LatestVersion, "2025-03-26", "2024-11-05"}
+
+// Códigos de error de JSON-RPC.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+// ErrUnknownTool indica que se pidió una herramienta que no existe.
+var ErrUnknownTool = errors.New("herramienta desconocida")
+
+// Request es un mensaje JSON-RPC: una petición, o una notificación si no
+// tiene ID.
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// IsNotification indica si el mensaje no espera respuesta.
+func (r *Request) IsNotification() bool {
+	return len(r.ID) == 0
+}
+
+// Response es la respuesta JSON-RPC a una petición.
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Error es un error JSON-RPC.
+type Error struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Implementation identifica al cliente o al servidor.
+type Implementation struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// InitializeParams son los parámetros de initialize.
+type InitializeParams struct {
+	ProtocolVersion string                 `json:"protocolVersion"`
+	Capabilities    map[string]interface{} `json:"capabilities"`
+	ClientInfo      Implementation         `json:"clientInfo"`
+}
+
+// InitializeResult es la respuesta a initialize.
+type InitializeResult struct {
+	ProtocolVersion string                 `json:"protocolVersion"`
+	Capabilities    map[string]interface{} `json:"capabilities"`
+	ServerInfo      Implementation         `json:"serverInfo"`
+	Instructions    string                 `json:"instructions,omitempty"`
+}
+
+// Tool describe una herramienta. InputSchema es el JSON Schema de sus
+// argumentos.
+type Tool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema map[string]interface{} `json:"inputSchema"`
+}
+
+// ListToolsResult es la respuesta a tools/list.
+type ListToolsResult struct {
+	Tools      []Tool `json:"tools"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// CallToolParams son los parámetros de tools/call.
+type CallToolParams struct {
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments,omitempty"`
+}
+
+// Content es un bloque de contenido del resultado de una herramienta.
+type Content struct {
+	Type string `json:"type"`
+	Text string `json:"text,omitempty"`
+}
+
+// CallToolResult es la respuesta a tools/call. Con IsError la herramienta
+// se ejecutó pero falló; el error va en Content para que lo vea el modelo.
+type CallToolResult struct {
+	Content           []Content              `json:"content"`
+	StructuredContent map[string]interface{} `json:"structuredContent,omitempty"`
+	IsError           bool                   `json:"isError,omitempty"`
+}
+
+// TextResult crea un resultado con un único bloque de texto.
+func TextResult(text string) *CallToolResult {
+	return &CallToolResult{Content: []Content{{Type: "text", Text: text}}}
+}
+
+// ErrorResult crea un resultado de error con el mensaje como texto.
+func ErrorResult(message string) *CallToolResult {
+	result := TextResult(message)
+	result.IsError = true
+	return result
+}
@@ -0,0 +1,176 @@
+package mcpproto
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ivanneira/Lapislazuli/internal/logger"
+)
+
+// ToolProvider es lo que el servidor expone como herramientas. CallTool
+// devuelve ErrUnknownTool si la herramienta no existe; los errores de la
+// ejecución van en el CallToolResult con IsError.
+type ToolProvider interface {
+	ListTools(ctx context.Context) []Tool
+	CallTool(ctx context.Context, name string, args map[string]interface{}) (*CallToolResult, error)
+}
+
+// Server atiende mensajes MCP. No guarda estado entre mensajes, por lo que
+// puede compartirse entre transportes y conexiones.
+type Server struct {
+	info  Implementation
+	tools ToolProvider
+}
+
+// NewServer crea un servidor que se presenta como name y version y expone
+// las herramientas de tools.
+func NewServer(name, version string, tools ToolProvider) *Server {
+	return &Server{info: Implementation{Name: name, Version: version}, tools: tools}
+}
+
+// Handle procesa un mensaje JSON-RPC, o un lote, y devuelve la respuesta
+// serializada. Devuelve nil si no hay nada que responder, como con las
+// notificaciones. Los transportes solo tienen que mover bytes, por lo que un
+// cliente en el mismo proceso puede llamar a Handle directamente.
+func (s *Server) Handle(ctx context.Context, data []byte) []byte {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(data, &batch); err != nil || len(batch) == 0 {
+			return marshal(errorResponse(nil, &Error{Code: CodeInvalidRequest, Message: "lote inválido"}))
+		}
+		var responses []*Response
+		for _, raw := range batch {
+			if resp := s.handleOne(ctx, raw); resp != nil {
+				responses = append(responses, resp)
+			}
+		}
+		if len(responses) == 0 {
+			return nil
+		}
+		return marshal(responses)
+	}
+
+	resp := s.handleOne(ctx, data)
+	if resp == nil {
+		return nil
+	}
+	return marshal(resp)
+}
+
+func (s *Server) handleOne(ctx context.Context, data []byte) *Response {
+	var req Request
+	if err := json.Unmarshal(data, &req); err != nil {
+		return errorResponse(nil, &Error{Code: CodeParseError, Message: err.Error()})
+	}
+	if req.Method == "" {
+		if req.IsNotification() {
+			return errorResponse(nil, &Error{Code: CodeInvalidRequest, Message: "falta el método"})
+		}
+		// Respuesta del cliente: el servidor no le hace peticiones, se ignora
+		return nil
+	}
+	if req.JSONRPC != "2.0" {
+		if req.IsNotification() {
+			return nil
+		}
+		return errorResponse(req.ID, &Error{Code: CodeInvalidRequest, Message: "jsonrpc debe ser 2.0"})
+	}
+
+	if req.IsNotification() {
+		logger.Debug("Notificación MCP: %s", req.Method)
+		return nil
+	}
+
+	result, err := s.dispatch(ctx, &req)
+	if err != nil {
+		var rpcErr *Error
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &Error{Code: CodeInternalError, Message: err.Error()}
+		}
+		return errorResponse(req.ID, rpcErr)
+	}
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return errorResponse(req.ID, &Error{Code: CodeInternalError, Message: err.Error()})
+	}
+	return &Response{JSONRPC: "2.0", ID: req.ID, Result: encoded}
+}
+
+// dispatch ejecuta el método de la petición.
+func (s *Server) dispatch(ctx context.Context, req *Request) (interface{}, error) {
+	logger.Info("Petición MCP: %s", req.Method)
+	switch req.Method {
+	case "initialize":
+		var params InitializeParams
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		return &InitializeResult{
+			ProtocolVersion: negotiateVersion(params.ProtocolVersion),
+			Capabilities: map[string]interface{}{
+				"tools": map[string]interface{}{"listChanged": false},
+			},
+			ServerInfo: s.info,
+		}, nil
+	case "ping":
+		return struct{}{}, nil
+	case "tools/list":
+		return &ListToolsResult{Tools: s.tools.ListTools(ctx)}, nil
+	case "tools/call":
+		var params CallToolParams
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		if params.Name == "" {
+			return nil, &Error{Code: CodeInvalidParams, Message: "falta el nombre de la herramienta"}
+		}
+		result, err := s.tools.CallTool(ctx, params.Name, params.Arguments)
+		if errors.Is(err, ErrUnknownTool) {
+			return nil, &Error{Code: CodeInvalidParams, Message: fmt.Sprintf("herramienta desconocida: %s", params.Name)}
+		}
+		return result, err
+	default:
+		return nil, &Error{Code: CodeMethodNotFound, Message: fmt.Sprintf("método desconocido: %s", req.Method)}
+	}
+}
+
+// negotiateVersion devuelve la versión pedida por el cliente si se soporta,
+// o la más nueva.
+func negotiateVersion(requested string) string {
+	for _, v := range SupportedVersions {
+		if v == requested {
+			return v
+		}
+	}
+	return LatestVersion
+}
+
+func decodeParams(raw json.RawMessage, v interface{}) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return &Error{Code: CodeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func errorResponse(id json.RawMessage, err *Error) *Response {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	return &Response{JSONRPC: "2.0", ID: id, Error: err}
+}
+
+func marshal(v interface{}) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		logger.Error("No se pudo serializar la respuesta MCP: %v", err)
+		return nil
+	}
+	return data
+}
@@ -0,0 +1,136 @@
+package mcpproto
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// fakeTools expone eco, que devuelve el texto que recibe, y falla, que
+// siempre falla.
+type fakeTools struct{}
+
+func (fakeTools) ListTools(ctx context.Context) []Tool {
+	return []Tool{
+		{
+			Name:        "eco",
+			Description: "Repite el texto",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{"texto": map[string]interface{}{"type": "string"}},
+				"required":   []interface{}{"texto"},
+			},
+		},
+		{Name: "falla", Description: "Siempre falla", InputSchema: map[string]interface{}{"type": "object"}},
+	}
+}
+
+func (fakeTools) CallTool(ctx context.Context, name string, args map[string]interface{}) (*CallToolResult, error) {
+	switch name {
+	case "eco":
+		text, _ := args["texto"].(string)
+		result := TextResult(text)
+		result.StructuredContent = args
+		return result, nil
+	case "falla":
+		return ErrorResult("no anda"), nil
+	}
+	return nil, ErrUnknownTool
+}
+
+func newTestServer() *Server {
+	return NewServer("prueba", "1.0", fakeTools{})
+}
+
+// exercise recorre initialize, tools/list y tools/call con client, como lo
+// haría un cliente MCP real.
+func exercise(t *testing.T, client *Client) {
+	t.Helper()
+	ctx := context.Background()
+
+	info, err := client.Initialize(ctx, Implementation{Name: "cliente", Version: "0.1"})
+	if err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	if info.ServerInfo != (Implementation{Name: "prueba", Version: "1.0"}) || info.ProtocolVersion != LatestVersion {
+		t.Errorf("initialize inesperado: %+v", info)
+	}
+
+	tools, err := client.ListTools(ctx)
+	if err != nil {
+		t.Fatalf("ListTools: %v", err)
+	}
+	if len(tools) != 2 || tools[0].Name != "eco" || tools[1].Name != "falla" {
+		t.Fatalf("herramientas inesperadas: %+v", tools)
+	}
+	if !reflect.DeepEqual(tools[0].InputSchema, fakeTools{}.ListTools(ctx)[0].InputSchema) {
+		t.Errorf("inputSchema inesperado: %v", tools[0].InputSchema)
+	}
+
+	args := map[string]interface{}{"texto": "hola", "lista": []interface{}{"a"}}
+	result, err := client.CallTool(ctx, "eco", args)
+	if err != nil {
+		t.Fatalf("CallTool eco: %v", err)
+	}
+	if result.IsError || len(result.Content) != 1 || result.Content[0].Text != "hola" {
+		t.Errorf("resultado inesperado: %+v", result)
+	}
+	var structured map[string]interface{}
+	if data, err := json.Marshal(result.StructuredContent); err != nil || json.Unmarshal(data, &structured) != nil ||
+		!reflect.DeepEqual(structured, args) {
+		t.Errorf("structuredContent = %v, se esperaban los argumentos", result.StructuredContent)
+	}
+
+	result, err = client.CallTool(ctx, "falla", nil)
+	if err != nil {
+		t.Fatalf("CallTool falla: %v", err)
+	}
+	if !result.IsError {
+		t.Errorf("el resultado debería marcarse como error: %+v", result)
+	}
+
+	_, err = client.CallTool(ctx, "nada", nil)
+	var rpcErr *Error
+	if !errors.As(err, &rpcErr) || rpcErr.Code != CodeInvalidParams {
+		t.Errorf("se esperaba invalid params por la herramienta desconocida, llegó %v", err)
+	}
+}
+
+func TestHandleNotification(t *testing.T) {
+	if resp := newTestServer().Handle(context.Background(), []byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)); resp != nil {
+		t.Errorf("una notificación no debería tener respuesta: %s", resp)
+	}
+}
+
+func TestHandleBatch(t *testing.T) {
+	resp := newTestServer().Handle(context.Background(), []byte(`[
+		{"jsonrpc":"2.0","id":1,"method":"ping"},
+		{"jsonrpc":"2.0","method":"notifications/initialized"},
+		{"jsonrpc":"2.0","id":2,"method":"desconocido"}
+	]`))
+	var responses []Response
+	if err := json.Unmarshal(resp, &responses); err != nil {
+		t.Fatalf("respuesta inválida: %s", resp)
+	}
+	if len(responses) != 2 {
+		t.Fatalf("se esperaban 2 respuestas: %s", resp)
+	}
+	if responses[0].Error != nil || string(responses[0].ID) != "1" {
+		t.Errorf("ping: %+v", responses[0])
+	}
+	if responses[1].Error == nil || responses[1].Error.Code != CodeMethodNotFound {
+		t.Errorf("método desconocido: %+v", responses[1])
+	}
+}
+
+func TestHandleInvalidJSON(t *testing.T) {
+	var resp Response
+	if err := json.Unmarshal(newTestServer().Handle(context.Background(), []byte(`{"jsonrpc":`)), &resp); err != nil {
+		t.Fatalf("respuesta inválida: %v", err)
+	}
+	if resp.Error == nil || resp.Error.Code != CodeParseError {
+		t.Errorf("se esperaba parse error: %+v", resp)
+	}
+}
@@ -0,0 +1,65 @@
+package mcpproto
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"sync"
+)
+
+// maxMessageSize es el tamaño máximo de un mensaje por stdio.
+const maxMessageSize = 16 << 20
+
+// ServeStdio atiende mensajes por stdio: un mensaje JSON-RPC por línea en r y
+// las respuestas, también una por línea, en w. Las peticiones se atienden en
+// paralelo, así un ping no espera a una herramienta lenta. Termina cuando r
+// se cierra, después de responder las peticiones pendientes, o cuando ctx se
+// cancela.
+func ServeStdio(ctx context.Context, server *Server, r io.Reader, w io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	lines := make(chan []byte)
+	errs := make(chan error, 1)
+	go func() {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+		for scanner.Scan() {
+			line := append([]byte(nil), scanner.Bytes()...)
+			select {
+			case lines <- line:
+			case <-ctx.Done():
+				return
+			}
+		}
+		errs <- scanner.Err()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-errs:
+			return err
+		case line := <-lines:
+			if len(line) == 0 {
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp := server.Handle(ctx, line)
+				if resp == nil {
+					return
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				w.Write(append(resp, '\n'))
+			}()
+		}
+	}
+}
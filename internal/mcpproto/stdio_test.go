@@ -0,0 +1,62 @@
+package mcpproto
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+// pipeClient conecta un Client con ServeStdio por dos io.Pipe. El servidor
+// termina cuando el cliente cierra su stdin.
+func pipeClient(t *testing.T, ctx context.Context) (*Client, <-chan error) {
+	t.Helper()
+	clientOut, serverIn := io.Pipe()
+	serverOut, clientIn := io.Pipe()
+
+	done := make(chan error, 1)
+	go func() {
+		err := ServeStdio(ctx, newTestServer(), clientOut, clientIn)
+		clientIn.Close()
+		done <- err
+	}()
+	return &Client{transport: newStdioTransport(nil, serverIn, serverOut)}, done
+}
+
+func TestServeStdio(t *testing.T) {
+	client, done := pipeClient(t, context.Background())
+	exercise(t, client)
+
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("ServeStdio: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ServeStdio no terminó al cerrarse stdin")
+	}
+}
+
+func TestServeStdioCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	client, done := pipeClient(t, ctx)
+	if _, err := client.Initialize(ctx, Implementation{Name: "cliente"}); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("ServeStdio = %v, se esperaba la cancelación", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ServeStdio no terminó al cancelarse el contexto")
+	}
+	if _, err := client.ListTools(context.Background()); err != errServerClosed {
+		t.Errorf("con el servidor terminado se esperaba %v, llegó %v", errServerClosed, err)
+	}
+}
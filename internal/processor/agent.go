@@ -0,0 +1,103 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ivanneira/Lapislazuli/config"
+	"github.com/ivanneira/Lapislazuli/internal/llm"
+	"github.com/ivanneira/Lapislazuli/internal/logger"
+	"github.com/ivanneira/Lapislazuli/internal/mcp"
+)
+
+// Tool y ToolCall son las herramientas que el agente ofrece al modelo y las
+// llamadas que este pide.
+type (
+	Tool     = llm.Tool
+	ToolCall = llm.ToolCall
+)
+
+// ErrMaxIterations indica que el agente agotó AGENT_MAX_ITERATIONS sin que
+// el modelo diera una respuesta final.
+var ErrMaxIterations = errors.New("el agente alcanzó el máximo de iteraciones sin responder")
+
+// ToolFunc ejecuta una herramienta pedida por el modelo y devuelve el texto
+// que se le envía como resultado. Los errores de la herramienta también se
+// devuelven como texto, para que el modelo pueda corregir la llamada.
+type ToolFunc func(ctx context.Context, call ToolCall) string
+
+// Agent responde el prompt con el modelo del rol agent, que puede llamar a
+// las herramientas tools: cada llamada se ejecuta con run y su resultado se
+// le devuelve como mensaje "tool", hasta que el modelo responde sin pedir
+// herramientas o se agotan las iteraciones. El prompt, las llamadas, sus
+// resultados y la respuesta final quedan en mctx.
+func Agent(ctx context.Context, mctx mcp.ModelContext, prompt, instruction string, tools []Tool, run ToolFunc) (string, Usage, error) {
+	logger.Info("=== Iniciando agente ===")
+	mctx.AddMessage(mcp.RoleUser, prompt)
+
+	messages := contextWindow(ctx, mctx, config.RoleAgent, []llm.Message{
+		{
+			Role:    mcp.RoleSystem,
+			Content: instruction,
+		},
+	}, nil)
+
+	var usage Usage
+	for i := 0; i < config.Config.AgentMaxIterations; i++ {
+		var resp *llm.ChatResponse
+		err := withProfile(ctx, config.RoleAgent, func(ctx context.Context, backend llm.Backend, profile config.ModelProfile) error {
+			req := chatRequest(profile, messages)
+			req.Tools = tools
+			var err error
+			resp, err = backend.Chat(ctx, req)
+			return err
+		})
+		if err != nil {
+			return "", usage, err
+		}
+		usage = usage.Add(resp.Usage)
+
+		if len(resp.ToolCalls) == 0 {
+			mctx.AddMessage(mcp.RoleAssistant, resp.Content)
+			return resp.Content, usage, nil
+		}
+
+		if resp.Content != "" {
+			mctx.AddMessage(mcp.RoleAssistant, resp.Content)
+		}
+		calls := resp.ToolCalls
+		for j := range calls {
+			if calls[j].ID == "" {
+				calls[j].ID = fmt.Sprintf("call_%d_%d", i, j)
+			}
+			if calls[j].Type == "" {
+				calls[j].Type = "function"
+			}
+		}
+		messages = append(messages, llm.Message{
+			Role:      mcp.RoleAssistant,
+			Content:   resp.Content,
+			ToolCalls: calls,
+		})
+
+		for _, call := range calls {
+			logger.Info("El agente llama a %s con %s", call.Function.Name, call.Function.Arguments)
+			encoded, _ := json.Marshal(call)
+			mctx.AddMessage(mcp.RoleToolCall, string(encoded))
+
+			result := run(ctx, call)
+			if err := ctx.Err(); err != nil {
+				return "", usage, err
+			}
+			mctx.AddMessage(mcp.RoleTool, result)
+			messages = append(messages, llm.Message{
+				Role:       mcp.RoleTool,
+				Content:    result,
+				ToolCallID: call.ID,
+			})
+		}
+	}
+	return "", usage, ErrMaxIterations
+}
@@ -0,0 +1,110 @@
+package processor
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ivanneira/Lapislazuli/config"
+	"github.com/ivanneira/Lapislazuli/internal/llm"
+	"github.com/ivanneira/Lapislazuli/internal/logger"
+)
+
+var (
+	backendsMu sync.Mutex
+	backends   = map[string]llm.Backend{}
+)
+
+// backendFor devuelve el backend del perfil, con reintentos y circuit
+// breaker. Se crea una sola vez por perfil para que el estado del circuito
+// se comparta entre peticiones.
+func backendFor(profile config.ModelProfile) (llm.Backend, error) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	if backend, ok := backends[profile.Name]; ok {
+		return backend, nil
+	}
+
+	backend, err := llm.New(llm.Config{
+		Provider:         profile.Provider,
+		URL:              profile.URL,
+		APIKey:           profile.APIKey,
+		StructuredOutput: profile.StructuredOutput,
+		StrictSchema:     profile.StrictSchema,
+	})
+	if err != nil {
+		return nil, err
+	}
+	backend = llm.WithBreaker(backend, llm.NewBreaker(config.Config.LMBreakerThreshold, config.Config.LMBreakerCooldown))
+	backend = llm.WithRetry(backend, llm.RetryPolicy{
+		MaxAttempts: config.Config.LMRetryAttempts,
+		BaseDelay:   config.Config.LMRetryBaseDelay,
+		MaxDelay:    config.Config.LMRetryMaxDelay,
+	})
+	if profile.Name != "" {
+		backends[profile.Name] = backend
+	}
+	return backend, nil
+}
+
+// withProfile llama a fn con el backend del perfil asignado a role. Si falla
+// con un error transitorio (agotados los reintentos o con el circuito
+// abierto), vuelve a intentar con el perfil de respaldo, si lo hay, salvo
+// que ctx ya se haya cancelado.
+func withProfile(ctx context.Context, role string, fn func(ctx context.Context, backend llm.Backend, profile config.ModelProfile) error) error {
+	profile := config.Model(role)
+	tried := map[string]bool{}
+	for {
+		tried[profile.Name] = true
+		err := callProfile(ctx, profile, fn)
+		if err == nil || ctx.Err() != nil || !llm.Retryable(err) || profile.Fallback == "" || tried[profile.Fallback] {
+			return err
+		}
+
+		fallback, ok := config.Profile(profile.Fallback)
+		if !ok {
+			return err
+		}
+		logger.Warn("El perfil %s no responde (%v), se usa el de respaldo %s", profile.Name, err, fallback.Name)
+		profile = fallback
+	}
+}
+
+// callProfile llama a fn con el backend del perfil y su timeout.
+func callProfile(ctx context.Context, profile config.ModelProfile, fn func(ctx context.Context, backend llm.Backend, profile config.ModelProfile) error) error {
+	backend, err := backendFor(profile)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := profileContext(ctx, profile)
+	defer cancel()
+	return fn(ctx, backend, profile)
+}
+
+// chatRequest arma una petición de chat con el modelo y los parámetros de
+// muestreo del perfil. Los parámetros en -1 no se envían.
+func chatRequest(profile config.ModelProfile, messages []llm.Message) llm.ChatRequest {
+	var opts llm.Options
+	if profile.Temperature != -1 {
+		opts.Temperature = &profile.Temperature
+	}
+	if profile.MaxTokens != -1 {
+		opts.MaxTokens = &profile.MaxTokens
+	}
+	if profile.TopK != -1 {
+		opts.TopK = &profile.TopK
+	}
+	if profile.TopP != -1 {
+		opts.TopP = &profile.TopP
+	}
+	if profile.MinP != -1 {
+		opts.MinP = &profile.MinP
+	}
+	if profile.RepetitionPenalty != -1 {
+		opts.RepetitionPenalty = &profile.RepetitionPenalty
+	}
+	return llm.ChatRequest{
+		Model:    profile.Model,
+		Messages: messages,
+		Options:  opts,
+	}
+}
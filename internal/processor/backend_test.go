@@ -0,0 +1,137 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ivanneira/Lapislazuli/config"
+	"github.com/ivanneira/Lapislazuli/internal/llm"
+)
+
+// fakeModel es un servidor de modelos compatible con OpenAI que responde
+// siempre con code y, si es 200, con content. Cuenta las llamadas.
+func fakeModel(t *testing.T, code int, content string, calls *atomic.Int32) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if code != http.StatusOK {
+			http.Error(w, http.StatusText(code), code)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"` + content + `"}}]}`))
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+// setProfiles configura el rol de prueba con el perfil principal y su
+// respaldo, y descarta los backends ya creados, que se guardan por nombre de
+// perfil.
+func setProfiles(t *testing.T, role string, primary, fallback config.ModelProfile) {
+	t.Helper()
+	saved := config.Config
+	t.Cleanup(func() { config.Config = saved })
+	backendsMu.Lock()
+	backends = map[string]llm.Backend{}
+	backendsMu.Unlock()
+
+	config.Config.LMRetryAttempts = 2
+	config.Config.LMRetryBaseDelay = time.Millisecond
+	config.Config.LMRetryMaxDelay = time.Millisecond
+	config.Config.LMBreakerThreshold = 0
+	config.Config.Models = map[string]config.ModelProfile{primary.Name: primary}
+	if fallback.Name != "" {
+		config.Config.Models[fallback.Name] = fallback
+	}
+	config.Config.Roles = map[string]string{role: primary.Name}
+}
+
+func profile(name, url, fallback string) config.ModelProfile {
+	return config.ModelProfile{
+		Name:              name,
+		URL:               url,
+		Model:             "m",
+		Fallback:          fallback,
+		Temperature:       -1,
+		MaxTokens:         -1,
+		TopK:              -1,
+		TopP:              -1,
+		MinP:              -1,
+		RepetitionPenalty: -1,
+	}
+}
+
+// chatWith llama a withProfile con un chat simple y devuelve el perfil que
+// respondió.
+func chatWith(role string) (string, error) {
+	var used string
+	err := withProfile(context.Background(), role, func(ctx context.Context, backend llm.Backend, profile config.ModelProfile) error {
+		resp, err := backend.Chat(ctx, chatRequest(profile, []llm.Message{{Role: "user", Content: "hola"}}))
+		if err != nil {
+			return err
+		}
+		used = resp.Content
+		return nil
+	})
+	return used, err
+}
+
+func TestWithProfileFallback(t *testing.T) {
+	var primaryCalls, fallbackCalls atomic.Int32
+	setProfiles(t, "prueba",
+		profile("caido", fakeModel(t, http.StatusServiceUnavailable, "", &primaryCalls), "respaldo"),
+		profile("respaldo", fakeModel(t, http.StatusOK, "respaldo", &fallbackCalls), ""),
+	)
+
+	used, err := chatWith("prueba")
+	if err != nil {
+		t.Fatalf("withProfile: %v", err)
+	}
+	if used != "respaldo" {
+		t.Errorf("respondió %q, se esperaba el perfil de respaldo", used)
+	}
+	if got := primaryCalls.Load(); got != 2 {
+		t.Errorf("el perfil principal recibió %d llamadas, se esperaban 2 (los reintentos)", got)
+	}
+	if got := fallbackCalls.Load(); got != 1 {
+		t.Errorf("el respaldo recibió %d llamadas, se esperaba 1", got)
+	}
+}
+
+func TestWithProfileNoFallbackOnClientError(t *testing.T) {
+	var primaryCalls, fallbackCalls atomic.Int32
+	setProfiles(t, "prueba",
+		profile("rechaza", fakeModel(t, http.StatusBadRequest, "", &primaryCalls), "respaldo-400"),
+		profile("respaldo-400", fakeModel(t, http.StatusOK, "respaldo", &fallbackCalls), ""),
+	)
+
+	_, err := chatWith("prueba")
+	var statusErr *llm.StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("se esperaba el 400 del perfil principal, llegó %v", err)
+	}
+	if got := fallbackCalls.Load(); got != 0 {
+		t.Errorf("un 400 no debería pasar al respaldo (%d llamadas)", got)
+	}
+}
+
+func TestWithProfileFallbackLoop(t *testing.T) {
+	var aCalls, bCalls atomic.Int32
+	setProfiles(t, "prueba",
+		profile("ciclo-a", fakeModel(t, http.StatusBadGateway, "", &aCalls), "ciclo-b"),
+		profile("ciclo-b", fakeModel(t, http.StatusBadGateway, "", &bCalls), "ciclo-a"),
+	)
+
+	if _, err := chatWith("prueba"); !llm.Retryable(err) {
+		t.Fatalf("se esperaba el error transitorio del respaldo, llegó %v", err)
+	}
+	if aCalls.Load() != 2 || bCalls.Load() != 2 {
+		t.Errorf("llamadas: %d y %d, se esperaban 2 a cada perfil", aCalls.Load(), bCalls.Load())
+	}
+}
@@ -0,0 +1,172 @@
+package processor
+
+import (
+	"encoding/json"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ivanneira/Lapislazuli/internal/llm"
+	"github.com/ivanneira/Lapislazuli/internal/logger"
+)
+
+// Candidate es una acción posible con su confianza, entre 0 y 1.
+type Candidate struct {
+	Action     string  `json:"action"`
+	Confidence float64 `json:"confidence"`
+}
+
+// Classification es el resultado del clasificador: la acción elegida, sus
+// parámetros, la confianza en la elección y las acciones candidatas ordenadas
+// de mayor a menor confianza.
+type Classification struct {
+	Action     string                 `json:"action"`
+	Params     map[string]interface{} `json:"params"`
+	Confidence float64                `json:"confidence"`
+	Candidates []Candidate            `json:"candidates,omitempty"`
+	Usage      Usage                  `json:"-"`
+}
+
+// rawClassification es lo que devuelve el modelo. La confianza y las
+// alternativas son las que el propio modelo informa.
+type rawClassification struct {
+	Action       string                 `json:"action"`
+	Params       map[string]interface{} `json:"params"`
+	Confidence   *float64               `json:"confidence"`
+	Alternatives []Candidate            `json:"alternatives,omitempty"`
+}
+
+// actionValue encuentra el valor del campo "action" en el JSON generado.
+var actionValue = regexp.MustCompile(`"action"\s*:\s*"`)
+
+// parseClassification decodifica la respuesta del clasificador. Si el
+// backend devolvió logprobs, la confianza sale de ellos; si no, se usa la que
+// informa el modelo.
+func parseClassification(resp *llm.ChatResponse) (*Classification, error) {
+	var raw rawClassification
+	if err := json.Unmarshal([]byte(resp.Content), &raw); err != nil {
+		return nil, err
+	}
+
+	result := &Classification{Action: raw.Action, Params: raw.Params, Confidence: 1, Usage: resp.Usage}
+	if raw.Confidence != nil {
+		result.Confidence = clamp(*raw.Confidence)
+	} else {
+		logger.Warn("El clasificador no informó la confianza de %s", raw.Action)
+	}
+	candidates := append([]Candidate{}, raw.Alternatives...)
+
+	if confidence, alternatives, ok := logprobConfidence(resp.Logprobs, raw.Action, classificationActions()); ok {
+		logger.Debug("Confianza por logprobs: %.3f (informada: %.3f)", confidence, result.Confidence)
+		result.Confidence = confidence
+		candidates = alternatives
+	}
+
+	result.Candidates = rankCandidates(result.Action, result.Confidence, candidates)
+	return result, nil
+}
+
+// logprobConfidence estima la probabilidad de la acción elegida como el
+// producto de las probabilidades de los tokens de su nombre. Las alternativas
+// salen de los tokens más probables en la primera posición del nombre: cada
+// uno se atribuye a las acciones que empiezan con ese texto.
+func logprobConfidence(tokens []llm.TokenLogprob, action string, names []string) (float64, []Candidate, bool) {
+	if len(tokens) == 0 || action == "" {
+		return 0, nil, false
+	}
+
+	var text strings.Builder
+	offsets := make([]int, len(tokens))
+	for i, t := range tokens {
+		offsets[i] = text.Len()
+		text.WriteString(t.Token)
+	}
+	loc := actionValue.FindStringIndex(text.String())
+	if loc == nil || !strings.HasPrefix(text.String()[loc[1]:], action) {
+		return 0, nil, false
+	}
+	start, end := loc[1], loc[1]+len(action)
+
+	first := -1
+	var logprob float64
+	for i, t := range tokens {
+		tokenEnd := offsets[i] + len(t.Token)
+		if tokenEnd <= start || offsets[i] >= end {
+			continue
+		}
+		if first == -1 {
+			first = i
+		}
+		logprob += t.Logprob
+	}
+	if first == -1 {
+		return 0, nil, false
+	}
+
+	// El primer token puede incluir texto anterior al nombre (por ejemplo las
+	// comillas); las alternativas solo cuentan si comparten ese prefijo.
+	prefix := text.String()[offsets[first]:start]
+	var alternatives []Candidate
+	for _, top := range tokens[first].TopLogprobs {
+		if !strings.HasPrefix(top.Token, prefix) {
+			continue
+		}
+		head := strings.TrimPrefix(top.Token, prefix)
+		if head == "" {
+			continue
+		}
+		var matches []string
+		for _, name := range names {
+			if name != action && (strings.HasPrefix(name, head) || strings.HasPrefix(head, name)) {
+				matches = append(matches, name)
+			}
+		}
+		for _, name := range matches {
+			alternatives = append(alternatives, Candidate{
+				Action:     name,
+				Confidence: math.Exp(top.Logprob) / float64(len(matches)),
+			})
+		}
+	}
+	return clamp(math.Exp(logprob)), alternatives, true
+}
+
+// rankCandidates arma la lista de candidatas con la acción elegida y las
+// alternativas conocidas, sin repetir y ordenada por confianza.
+func rankCandidates(action string, confidence float64, alternatives []Candidate) []Candidate {
+	best := map[string]float64{action: confidence}
+	for _, c := range alternatives {
+		if c.Action == action || !isAction(c.Action) {
+			continue
+		}
+		if current, ok := best[c.Action]; !ok || clamp(c.Confidence) > current {
+			best[c.Action] = clamp(c.Confidence)
+		}
+	}
+
+	candidates := make([]Candidate, 0, len(best))
+	for name, conf := range best {
+		candidates = append(candidates, Candidate{Action: name, Confidence: conf})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Confidence != candidates[j].Confidence {
+			return candidates[i].Confidence > candidates[j].Confidence
+		}
+		return candidates[i].Action < candidates[j].Action
+	})
+	return candidates
+}
+
+func isAction(name string) bool {
+	for _, n := range classificationActions() {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func clamp(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
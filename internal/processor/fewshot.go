@@ -0,0 +1,64 @@
+package processor
+
+import (
+	"encoding/json"
+
+	"github.com/ivanneira/Lapislazuli/config"
+	"github.com/ivanneira/Lapislazuli/internal/examples"
+	"github.com/ivanneira/Lapislazuli/internal/llm"
+	"github.com/ivanneira/Lapislazuli/internal/mcp"
+)
+
+// fewShotConfidence es la confianza que muestran las respuestas de ejemplo.
+const fewShotConfidence = 0.9
+
+// fewShotExamples elige los ejemplos few-shot para el prompt según FEW_SHOT:
+// todos, los FEW_SHOT_K más parecidos al prompt por BM25, o ninguno.
+func fewShotExamples(prompt string) []examples.Example {
+	store := config.Config.Examples
+	if store.Len() == 0 {
+		return nil
+	}
+	switch config.Config.FewShot {
+	case config.FewShotAll:
+		return store.All()
+	case config.FewShotBM25:
+		return store.Similar(prompt, config.Config.FewShotK)
+	default:
+		return nil
+	}
+}
+
+// fewShotMessages devuelve los ejemplos few-shot para el prompt como pares
+// de mensajes usuario/asistente, con la respuesta que daría el clasificador.
+func fewShotMessages(prompt string) []llm.Message {
+	confidence := fewShotConfidence
+	return exampleMessages(fewShotExamples(prompt), func(e examples.Example, params map[string]interface{}) interface{} {
+		return rawClassification{
+			Action:     e.Action,
+			Params:     params,
+			Confidence: &confidence,
+		}
+	})
+}
+
+// exampleMessages arma un par de mensajes usuario/asistente por ejemplo, con
+// la respuesta que devuelve answer serializada en JSON.
+func exampleMessages(selected []examples.Example, answer func(e examples.Example, params map[string]interface{}) interface{}) []llm.Message {
+	messages := make([]llm.Message, 0, 2*len(selected))
+	for _, e := range selected {
+		params := e.Params
+		if params == nil {
+			params = map[string]interface{}{}
+		}
+		content, err := json.Marshal(answer(e, params))
+		if err != nil {
+			continue
+		}
+		messages = append(messages,
+			llm.Message{Role: mcp.RoleUser, Content: e.Text},
+			llm.Message{Role: mcp.RoleAssistant, Content: string(content)},
+		)
+	}
+	return messages
+}
@@ -0,0 +1,71 @@
+package processor
+
+import (
+	"strings"
+
+	"github.com/ivanneira/Lapislazuli/config"
+	"github.com/ivanneira/Lapislazuli/internal/actions"
+)
+
+// jsonGrammar son las reglas GBNF de un valor JSON genérico.
+const jsonGrammar = `object ::= "{" ws ( member ( ws "," ws member )* )? ws "}"
+member ::= string ws ":" ws value
+value ::= object | array | string | number | "true" | "false" | "null"
+array ::= "[" ws ( value ( ws "," ws value )* )? ws "]"
+string ::= "\"" ( [^"\\\x7F\x00-\x1F] | "\\" ( ["\\/bfnrt] | "u" hex hex hex hex ) )* "\""
+hex ::= [0-9a-fA-F]
+number ::= "-"? [0-9]+ ( "." [0-9]+ )? ( [eE] [-+]? [0-9]+ )?
+ws ::= ( [ \t\n] ws )?
+`
+
+// classificationGrammar genera la gramática GBNF equivalente al esquema de
+// clasificación, para los backends que no soportan json_schema: la acción
+// solo puede ser una de las configuradas o "none", params un objeto JSON y
+// confidence un número.
+func classificationGrammar() string {
+	names := classificationActions()
+	alternatives := make([]string, len(names))
+	for i, name := range names {
+		alternatives[i] = gbnfLiteral(`"` + name + `"`)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(`root ::= "{" ws "\"action\"" ws ":" ws action ws "," ws "\"params\"" ws ":" ws object ws "," ws "\"confidence\"" ws ":" ws number ws "}" ws`)
+	sb.WriteString("\n")
+	sb.WriteString("action ::= ")
+	sb.WriteString(strings.Join(alternatives, " | "))
+	sb.WriteString("\n")
+	sb.WriteString(jsonGrammar)
+	return sb.String()
+}
+
+// planGrammar genera la gramática GBNF equivalente al esquema del plan.
+func planGrammar() string {
+	names := actions.Names(config.Config.Manifests)
+	alternatives := make([]string, len(names))
+	for i, name := range names {
+		alternatives[i] = gbnfLiteral(`"` + name + `"`)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(`root ::= "{" ws "\"steps\"" ws ":" ws "[" ws ( step ( ws "," ws step )* )? ws "]" ws "," ws "\"confidence\"" ws ":" ws number ws "}" ws`)
+	sb.WriteString("\n")
+	sb.WriteString(`step ::= "{" ws "\"id\"" ws ":" ws string ws "," ws "\"action\"" ws ":" ws action ws "," ws "\"params\"" ws ":" ws object ws "," ws "\"depends_on\"" ws ":" ws "[" ws ( string ( ws "," ws string )* )? ws "]" ws "}"`)
+	sb.WriteString("\n")
+	sb.WriteString("action ::= ")
+	if len(alternatives) == 0 {
+		// Sin acciones el plan solo puede estar vacío
+		sb.WriteString(`"\"\""`)
+	} else {
+		sb.WriteString(strings.Join(alternatives, " | "))
+	}
+	sb.WriteString("\n")
+	sb.WriteString(jsonGrammar)
+	return sb.String()
+}
+
+// gbnfLiteral escribe s como literal de GBNF.
+func gbnfLiteral(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
@@ -0,0 +1,295 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ivanneira/Lapislazuli/config"
+	"github.com/ivanneira/Lapislazuli/internal/actions"
+	"github.com/ivanneira/Lapislazuli/internal/examples"
+	"github.com/ivanneira/Lapislazuli/internal/llm"
+	"github.com/ivanneira/Lapislazuli/internal/logger"
+	"github.com/ivanneira/Lapislazuli/internal/mcp"
+)
+
+// Step es un paso del plan: una acción con sus parámetros y los pasos que
+// tienen que terminar antes. Los parámetros de texto pueden referirse al
+// resultado de un paso anterior con {{<id>.message}}, {{<id>.status}} o
+// {{<id>.data.<campo>}}.
+type Step struct {
+	ID        string                 `json:"id"`
+	Action    string                 `json:"action"`
+	Params    map[string]interface{} `json:"params"`
+	DependsOn []string               `json:"depends_on"`
+}
+
+// Plan es el resultado del clasificador en modo planificador: los pasos en
+// el orden en que el usuario los pidió y la confianza en el plan. Un plan
+// sin pasos indica que el prompt no corresponde a ninguna acción.
+type Plan struct {
+	Steps      []Step  `json:"steps"`
+	Confidence float64 `json:"confidence"`
+	Usage      Usage   `json:"-"`
+}
+
+// rawPlan es lo que devuelve el modelo.
+type rawPlan struct {
+	Steps      []Step   `json:"steps"`
+	Confidence *float64 `json:"confidence"`
+}
+
+// stepReference encuentra las referencias a resultados de otros pasos.
+var stepReference = regexp.MustCompile(`\{\{\s*([^{}.\s]+)\.([^{}\s]+)\s*\}\}`)
+
+// onlyStepReference reconoce un texto que es únicamente una referencia.
+var onlyStepReference = regexp.MustCompile(`^\s*\{\{\s*([^{}.\s]+)\.([^{}\s]+)\s*\}\}\s*$`)
+
+// OnlyStepReference indica si s es únicamente una referencia a otro paso, y
+// devuelve su id y ruta.
+func OnlyStepReference(s string) (id, path string, ok bool) {
+	m := onlyStepReference.FindStringSubmatch(s)
+	if m == nil {
+		return "", "", false
+	}
+	return m[1], m[2], true
+}
+
+// StepReferences devuelve las referencias a otros pasos en s, como pares
+// [id, ruta], por ejemplo ["1", "data.email"].
+func StepReferences(s string) [][2]string {
+	var refs [][2]string
+	for _, m := range stepReference.FindAllStringSubmatch(s, -1) {
+		refs = append(refs, [2]string{m[1], m[2]})
+	}
+	return refs
+}
+
+// ReplaceStepReferences reemplaza en s cada referencia por lo que devuelve fn.
+func ReplaceStepReferences(s string, fn func(id, path string) string) string {
+	return stepReference.ReplaceAllStringFunc(s, func(ref string) string {
+		m := stepReference.FindStringSubmatch(ref)
+		return fn(m[1], m[2])
+	})
+}
+
+// ProcessPlan arma con el modelo clasificador el plan de pasos del prompt.
+func ProcessPlan(ctx context.Context, prompt string) (*Plan, error) {
+	if c := route(ctx, prompt); c != nil {
+		return routedPlan(c), nil
+	}
+	messages := []llm.Message{
+		{
+			Role:    mcp.RoleSystem,
+			Content: plannerSystemPrompt(),
+		},
+	}
+	messages = append(messages, planFewShotMessages(prompt)...)
+	messages = append(messages, llm.Message{
+		Role:    mcp.RoleUser,
+		Content: prompt,
+	})
+	return plan(ctx, messages)
+}
+
+// ProcessPlanWithContext es como ProcessPlan pero con el historial de la
+// sesión. El prompt queda agregado al contexto.
+func ProcessPlanWithContext(ctx context.Context, mctx mcp.ModelContext, prompt string) (*Plan, error) {
+	logger.Info("=== Iniciando planificación con contexto ===")
+	mctx.AddMessage(mcp.RoleUser, prompt)
+	if c := route(ctx, prompt); c != nil {
+		return routedPlan(c), nil
+	}
+
+	before := append([]llm.Message{
+		{
+			Role:    mcp.RoleSystem,
+			Content: plannerSystemPrompt(),
+		},
+	}, planFewShotMessages(prompt)...)
+	messages := contextWindow(ctx, mctx, config.RoleClassifier, before, nil)
+	return plan(ctx, messages)
+}
+
+// routedPlan convierte la acción elegida por el router en un plan de un
+// paso, o en uno vacío si es actions.None.
+func routedPlan(c *Classification) *Plan {
+	p := &Plan{Steps: []Step{}, Confidence: c.Confidence}
+	if c.Action != actions.None {
+		p.Steps = append(p.Steps, Step{ID: "1", Action: c.Action, Params: c.Params, DependsOn: []string{}})
+	}
+	return p
+}
+
+// plan envía los mensajes al modelo clasificador pidiendo un plan que
+// respete el esquema.
+func plan(ctx context.Context, messages []llm.Message) (*Plan, error) {
+	var resp *llm.ChatResponse
+	err := withProfile(ctx, config.RoleClassifier, func(ctx context.Context, backend llm.Backend, profile config.ModelProfile) error {
+		logger.Info("Iniciando petición de plan (perfil %s)", profile.Name)
+		var err error
+		resp, err = backend.Structured(ctx, chatRequest(profile, messages), llm.Schema{
+			Name:    "plan_response",
+			Schema:  planSchema(profile.StrictSchema),
+			Grammar: planGrammar(),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parsePlan(resp)
+}
+
+// parsePlan decodifica y valida el plan: completa los id que falten, agrega
+// a depends_on los pasos referidos en los parámetros y verifica que cada
+// paso dependa solo de pasos anteriores, lo que descarta ciclos.
+func parsePlan(resp *llm.ChatResponse) (*Plan, error) {
+	var raw rawPlan
+	if err := json.Unmarshal([]byte(resp.Content), &raw); err != nil {
+		return nil, err
+	}
+
+	result := &Plan{Steps: raw.Steps, Confidence: 1, Usage: resp.Usage}
+	if result.Steps == nil {
+		result.Steps = []Step{}
+	}
+	if raw.Confidence != nil {
+		result.Confidence = clamp(*raw.Confidence)
+	} else {
+		logger.Warn("El planificador no informó la confianza del plan")
+	}
+	if max := config.Config.PlanMaxSteps; max > 0 && len(result.Steps) > max {
+		return nil, fmt.Errorf("el plan tiene %d pasos y el máximo es %d", len(result.Steps), max)
+	}
+
+	seen := map[string]bool{}
+	for i := range result.Steps {
+		step := &result.Steps[i]
+		if step.ID == "" {
+			step.ID = strconv.Itoa(i + 1)
+		}
+		if seen[step.ID] {
+			return nil, fmt.Errorf("el plan repite el paso %s", step.ID)
+		}
+		if step.Params == nil {
+			step.Params = map[string]interface{}{}
+		}
+
+		deps := map[string]bool{}
+		for _, dep := range step.DependsOn {
+			deps[dep] = true
+		}
+		for _, value := range step.Params {
+			if s, ok := value.(string); ok {
+				for _, ref := range StepReferences(s) {
+					if !deps[ref[0]] {
+						deps[ref[0]] = true
+						step.DependsOn = append(step.DependsOn, ref[0])
+					}
+				}
+			}
+		}
+		if step.DependsOn == nil {
+			step.DependsOn = []string{}
+		}
+		for _, dep := range step.DependsOn {
+			if !seen[dep] {
+				return nil, fmt.Errorf("el paso %s depende de %s, que no es un paso anterior", step.ID, dep)
+			}
+		}
+		seen[step.ID] = true
+	}
+	return result, nil
+}
+
+// planSchema genera el JSON Schema del plan: una lista de pasos, cada uno
+// con una de las acciones configuradas y sus parámetros. Con strict las
+// variantes solo restringen "params" (ver variantParams).
+func planSchema(strict bool) map[string]interface{} {
+	variants := make([]interface{}, 0, len(config.Config.Manifests))
+	for i := range config.Config.Manifests {
+		m := &config.Config.Manifests[i]
+		variants = append(variants, map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"action": map[string]interface{}{
+					"type": "string",
+					"enum": []string{m.Name},
+				},
+				"params": m.ParamsSchema(),
+			},
+			"required": []string{"action", "params"},
+		})
+	}
+
+	steps := map[string]interface{}{
+		"type": "array",
+		"items": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"id": map[string]interface{}{
+					"type": "string",
+				},
+				"action": map[string]interface{}{
+					"type": "string",
+					"enum": actions.Names(config.Config.Manifests),
+				},
+				"params": map[string]interface{}{
+					"type": "object",
+				},
+				"depends_on": map[string]interface{}{
+					"type":  "array",
+					"items": map[string]interface{}{"type": "string"},
+				},
+			},
+			"required": []string{"id", "action", "params", "depends_on"},
+		},
+	}
+	item := steps["items"].(map[string]interface{})
+	if strict {
+		item["properties"].(map[string]interface{})["params"] = variantParams(variants)
+	} else {
+		item["anyOf"] = variants
+	}
+	if config.Config.PlanMaxSteps > 0 {
+		steps["maxItems"] = config.Config.PlanMaxSteps
+	}
+
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"steps":      steps,
+			"confidence": confidenceSchema(),
+		},
+		"required": []string{"steps", "confidence"},
+	}
+}
+
+// plannerSystemPrompt arma el prompt de sistema del planificador: las
+// acciones disponibles, igual que para el clasificador, y cómo armar los pasos.
+func plannerSystemPrompt() string {
+	var sb strings.Builder
+	sb.WriteString(actionsDescription())
+	sb.WriteString("Convierte el siguiente prompt en un plan: un JSON con 'steps', la lista de acciones a ejecutar en el orden pedido. ")
+	sb.WriteString("Cada paso tiene un 'id' único, la 'action', sus 'params' y en 'depends_on' los id de los pasos que tienen que terminar antes; los pasos independientes pueden ejecutarse en paralelo. ")
+	sb.WriteString("Para usar el resultado de un paso anterior en un parámetro escribe {{<id>.message}} o {{<id>.data.<campo>}}. ")
+	sb.WriteString("Si el prompt no corresponde a ninguna de las acciones, devuelve 'steps' vacío. ")
+	sb.WriteString("En 'confidence' indica, entre 0 y 1, qué tan seguro estás del plan.")
+	return sb.String()
+}
+
+// planFewShotMessages devuelve los ejemplos few-shot como planes de un paso.
+func planFewShotMessages(prompt string) []llm.Message {
+	confidence := fewShotConfidence
+	return exampleMessages(fewShotExamples(prompt), func(e examples.Example, params map[string]interface{}) interface{} {
+		steps := []Step{}
+		if e.Action != actions.None {
+			steps = append(steps, Step{ID: "1", Action: e.Action, Params: params, DependsOn: []string{}})
+		}
+		return rawPlan{Steps: steps, Confidence: &confidence}
+	})
+}
@@ -1,377 +1,278 @@
 package processor
 
 import (
-	"bytes"
-	"encoding/json"
-	"errors"
-	"fmt"
-	"io"
-	"io/ioutil"
-	"net/http"
-	"os"
-	"strconv"
+	"context"
 	"strings"
-	"sync"
-	"time"
 
 	"github.com/ivanneira/Lapislazuli/config"
+	"github.com/ivanneira/Lapislazuli/internal/actions"
+	"github.com/ivanneira/Lapislazuli/internal/llm"
 	"github.com/ivanneira/Lapislazuli/internal/logger"
 	"github.com/ivanneira/Lapislazuli/internal/mcp"
 )
 
-var (
-	bufferPool = sync.Pool{
-		New: func() interface{} {
-			return new(bytes.Buffer)
-		},
-	}
+// Usage son los tokens consumidos por las llamadas a los modelos.
+type Usage = llm.Usage
 
-	httpClient = &http.Client{
-		Timeout: 30 * time.Second,
-		Transport: &http.Transport{
-			MaxIdleConns:        100,
-			MaxIdleConnsPerHost: 100,
-			IdleConnTimeout:     90 * time.Second,
-		},
+// profileContext deriva de ctx un contexto con el timeout del perfil, si
+// tiene uno. Si ctx vence antes, vale el plazo de ctx.
+func profileContext(ctx context.Context, profile config.ModelProfile) (context.Context, context.CancelFunc) {
+	if profile.Timeout > 0 {
+		return context.WithTimeout(ctx, profile.Timeout)
 	}
-)
-
-type ChatMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
-}
-
-type LMResponseFormat struct {
-	Type       string                 `json:"type"`
-	JSONSchema map[string]interface{} `json:"json_schema"`
+	return context.WithCancel(ctx)
 }
 
-type LMChatRequest struct {
-	Model             string           `json:"model"`
-	Messages          []ChatMessage    `json:"messages"`
-	ResponseFormat    LMResponseFormat `json:"response_format"`
-	Temperature       *float32         `json:"temperature,omitempty"`
-	MaxTokens         *int             `json:"max_tokens,omitempty"`
-	Stream            bool             `json:"stream"`
-	TopK              *int             `json:"top_k,omitempty"`
-	TopP              *float32         `json:"top_p,omitempty"`
-	MinP              *float32         `json:"min_p,omitempty"`
-	RepetitionPenalty *float32         `json:"repetition_penalty,omitempty"`
-}
-
-type LMResponse struct {
-	Choices []struct {
-		Message ChatMessage `json:"message"`
-	} `json:"choices"`
-}
-
-// RequestPayload representa el JSON enviado al modelo LLM.
-type RequestPayload struct {
-	Model       string  `json:"model"`
-	Prompt      string  `json:"prompt"`
-	Temperature float32 `json:"temperature"`
-	MaxTokens   int     `json:"max_tokens"`
-	TopK        int     `json:"top_k"`
-	TopP        float32 `json:"top_p"`
-	MinP        float32 `json:"min_p"`
-}
-
-// Nueva función auxiliar para manejar solicitudes HTTP
-func sendLMRequest(requestBody LMChatRequest) (*LMResponse, error) {
-	logger.Info("Iniciando petición LLM")
-	logger.JSON("Request body", requestBody)
-
-	buf := bufferPool.Get().(*bytes.Buffer)
-	buf.Reset()
-	defer bufferPool.Put(buf)
-
-	if err := json.NewEncoder(buf).Encode(requestBody); err != nil {
-		logger.Error("Error codificando JSON: %v", err)
-		return nil, err
-	}
-
-	logger.Debug("URL destino: %s", config.Config.ClassificatorLMAPIURL)
-	req, err := http.NewRequest("POST", config.Config.ClassificatorLMAPIURL, buf)
-	if err != nil {
-		logger.Error("Error creando request: %v", err)
-		return nil, err
-	}
-
-	logger.Debug("Configurando headers")
-	req.Header.Set("Content-Type", "application/json")
-	if config.Config.ClassificatorAPIKey != "" {
-		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", config.Config.ClassificatorAPIKey))
-	}
-
-	logger.Info("Enviando petición HTTP")
-	resp, err := httpClient.Do(req)
+// classify envía los mensajes al modelo clasificador pidiendo una respuesta
+// que respete el esquema de clasificación.
+func classify(ctx context.Context, messages []llm.Message) (*Classification, error) {
+	var resp *llm.ChatResponse
+	err := withProfile(ctx, config.RoleClassifier, func(ctx context.Context, backend llm.Backend, profile config.ModelProfile) error {
+		logger.Info("Iniciando petición LLM (perfil %s)", profile.Name)
+		req := chatRequest(profile, messages)
+		req.TopLogprobs = config.Config.ClassificationLogprobs
+		var err error
+		resp, err = backend.Structured(ctx, req, llm.Schema{
+			Name:    "classification_response",
+			Schema:  classificationSchema(profile.StrictSchema),
+			Grammar: classificationGrammar(),
+		})
+		return err
+	})
 	if err != nil {
-		logger.Error("HTTP request error: %v", err)
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	logger.Info("Respuesta recibida, estado: %d", resp.StatusCode)
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		logger.Error("Error en la llamada a LM Studio: %s", string(bodyBytes))
-		return nil, fmt.Errorf("error en la llamada a LM Studio: %s", string(bodyBytes))
-	}
-
-	var lmResp LMResponse
-	if err := json.NewDecoder(resp.Body).Decode(&lmResp); err != nil {
-		logger.Error("Error decodificando respuesta: %v", err)
-		return nil, err
-	}
-
-	if len(lmResp.Choices) == 0 {
-		return nil, fmt.Errorf("no se recibieron respuestas del modelo")
-	}
+	return parseClassification(resp)
+}
 
-	logger.JSON("Respuesta completa", lmResp)
-	return &lmResp, nil
+// classificationActions devuelve los valores posibles de "action": las
+// acciones configuradas y actions.None.
+func classificationActions() []string {
+	return append(actions.Names(config.Config.Manifests), actions.None)
 }
 
-// Nueva función auxiliar para crear el cuerpo de la solicitud
-func createLMRequestBody(messages []ChatMessage) LMChatRequest {
-	var temp *float32 = nil
-	if config.Config.ClassificatorTemperature != -1 {
-		temp = &config.Config.ClassificatorTemperature
-	}
-	var maxTokens *int = nil
-	if config.Config.ClassificatorMaxTokens != -1 {
-		maxTokens = &config.Config.ClassificatorMaxTokens
-	}
-	var topK *int = nil
-	if config.Config.ClassificatorTopK != -1 {
-		topK = &config.Config.ClassificatorTopK
-	}
-	var topP *float32 = nil
-	if config.Config.ClassificatorTopP != -1 {
-		topP = &config.Config.ClassificatorTopP
-	}
-	var minP *float32 = nil
-	if config.Config.ClassificatorMinP != -1 {
-		minP = &config.Config.ClassificatorMinP
-	}
-	var repPenalty *float32 = nil
-	if config.Config.ClassificatorRepetitionPenalty != -1 {
-		repPenalty = &config.Config.ClassificatorRepetitionPenalty
+// classificationSchema genera el JSON Schema de la clasificación: "action"
+// restringida a las acciones configuradas más actions.None, y una variante
+// por acción con el esquema de sus propios parámetros. Con strict las
+// variantes solo restringen "params" (ver variantParams).
+func classificationSchema(strict bool) map[string]interface{} {
+	variants := make([]interface{}, 0, len(config.Config.Manifests)+1)
+	for i := range config.Config.Manifests {
+		m := &config.Config.Manifests[i]
+		variants = append(variants, map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"action": map[string]interface{}{
+					"type": "string",
+					"enum": []string{m.Name},
+				},
+				"params": m.ParamsSchema(),
+			},
+			"required": []string{"action", "params"},
+		})
 	}
-
-	return LMChatRequest{
-		Model:    config.Config.ClassificatorModelName,
-		Messages: messages,
-		ResponseFormat: LMResponseFormat{
-			Type: "json_schema",
-			JSONSchema: map[string]interface{}{
-				"name":   "classification_response",
-				"strict": "true",
-				"schema": map[string]interface{}{
+	variants = append(variants, map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"action": map[string]interface{}{
+				"type": "string",
+				"enum": []string{actions.None},
+			},
+			"params": map[string]interface{}{
+				"type": "object",
+			},
+		},
+		"required": []string{"action", "params"},
+	})
+
+	schema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"action": map[string]interface{}{
+				"type": "string",
+				"enum": classificationActions(),
+			},
+			"params": map[string]interface{}{
+				"type": "object",
+			},
+			"confidence": confidenceSchema(),
+			"alternatives": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
 					"type": "object",
 					"properties": map[string]interface{}{
 						"action": map[string]interface{}{
 							"type": "string",
+							"enum": classificationActions(),
 						},
+						"confidence": confidenceSchema(),
 					},
-					"required": []string{"action"},
+					"required": []string{"action", "confidence"},
 				},
 			},
 		},
-		Temperature:       temp,
-		MaxTokens:         maxTokens,
-		Stream:            false,
-		TopK:              topK,
-		TopP:              topP,
-		MinP:              minP,
-		RepetitionPenalty: repPenalty,
-	}
-}
-
-// Process realiza la clasificación usando el modelo clasificador.
-func Process(prompt string) (string, error) {
-	messages := []ChatMessage{
-		{
-			Role: "system",
-			Content: fmt.Sprintf("Acciones disponibles: %s. Clasifica el siguiente prompt devolviendo un JSON con el campo 'action'.",
-				strings.Join(config.Config.Actions, ", ")),
-		},
-		{
-			Role:    "user",
-			Content: prompt,
-		},
+		"required": []string{"action", "params", "confidence"},
 	}
-
-	requestBody := createLMRequestBody(messages)
-	resp, err := sendLMRequest(requestBody)
-	if err != nil {
-		return "", err
+	if strict {
+		schema["properties"].(map[string]interface{})["params"] = variantParams(variants)
+	} else {
+		schema["anyOf"] = variants
 	}
-
-	return resp.Choices[0].Message.Content, nil
+	return schema
 }
 
-// ProcessWithContext realiza la clasificación usando el contexto del modelo
-func ProcessWithContext(ctx mcp.ModelContext, prompt string) (string, error) {
-	logger.Info("=== Iniciando procesamiento con contexto ===")
-	logger.Debug("Prompt recibido: %s", prompt)
-	logger.JSON("Contexto actual", ctx.GetMessages())
-
-	systemContent := fmt.Sprintf(
-		"Acciones disponibles: %s. Clasifica el siguiente prompt devolviendo un JSON con el campo 'action'.",
-		strings.Join(config.Config.Actions, ", "),
-	)
-	logger.Debug("System prompt: %s", systemContent)
-	ctx.AddMessage("system", systemContent)
-	ctx.AddMessage("user", prompt)
-
-	messages := make([]ChatMessage, 0)
-	for _, msg := range ctx.GetMessages() {
-		messages = append(messages, ChatMessage{
-			Role:    msg.Role,
-			Content: msg.Content,
-		})
+// variantParams junta en un anyOf los esquemas de "params" de las variantes
+// por acción. El modo strict de OpenAI no admite anyOf al lado de properties,
+// así que se pierde el vínculo entre la acción y sus parámetros; los
+// parámetros igual se validan contra el manifiesto de la acción elegida.
+func variantParams(variants []interface{}) map[string]interface{} {
+	if len(variants) == 0 {
+		return map[string]interface{}{"type": "object"}
 	}
-
-	requestBody := createLMRequestBody(messages)
-	resp, err := sendLMRequest(requestBody)
-	if err != nil {
-		return "", err
+	params := make([]interface{}, len(variants))
+	for i, v := range variants {
+		params[i] = v.(map[string]interface{})["properties"].(map[string]interface{})["params"]
 	}
-
-	return resp.Choices[0].Message.Content, nil
+	return map[string]interface{}{"anyOf": params}
 }
 
-// Respond realiza una respuesta usando el modelo de respuestas.
-func Respond(prompt string) (string, error) {
-	apiURL := os.Getenv("RESPONSE_LM_API_URL")
-	model := os.Getenv("RESPONSE_MODEL_NAME")
-	temperature := os.Getenv("RESPONSE_LM_TEMPERATURE")
-	maxTokens := os.Getenv("RESPONSE_LM_MAX_TOKENS")
-	topK := os.Getenv("RESPONSE_LM_TOP_K")
-	topP := os.Getenv("RESPONSE_LM_TOP_P")
-	minP := os.Getenv("RESPONSE_LM_MIN_P")
-
-	payload := RequestPayload{
-		Model:       model,
-		Prompt:      prompt,
-		Temperature: parseFloat(temperature),
-		MaxTokens:   parseInt(maxTokens),
-		TopK:        parseInt(topK),
-		TopP:        parseFloat(topP),
-		MinP:        parseFloat(minP),
+func confidenceSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":    "number",
+		"minimum": 0,
+		"maximum": 1,
 	}
-
-	return makeRequest(apiURL, payload)
 }
 
-// RespondWithContext realiza una respuesta usando el contexto del modelo
-func RespondWithContext(ctx mcp.ModelContext, prompt string) (string, error) {
-	ctx.AddMessage("user", prompt)
+// classifierSystemPrompt arma el prompt de sistema del clasificador a partir de
+// los manifiestos: nombre, descripción y ejemplos de cada acción.
+func classifierSystemPrompt() string {
+	var sb strings.Builder
+	sb.WriteString(actionsDescription())
+	sb.WriteString("Clasifica el siguiente prompt devolviendo un JSON con el campo 'action' y, en 'params', los parámetros de la acción que se mencionen en el prompt. ")
+	sb.WriteString("Si el prompt no corresponde a ninguna de las acciones, devuelve 'action': '" + actions.None + "'. ")
+	sb.WriteString("En 'confidence' indica, entre 0 y 1, qué tan seguro estás de la acción elegida y, si dudas, lista en 'alternatives' las otras acciones posibles con su confianza.")
+	return sb.String()
+}
 
-	messages := make([]ChatMessage, 0)
-	for _, msg := range ctx.GetMessages() {
-		messages = append(messages, ChatMessage{
-			Role:    msg.Role,
-			Content: msg.Content,
-		})
-	}
+// actionsDescription lista las acciones configuradas con su descripción,
+// ejemplos y parámetros.
+func actionsDescription() string {
+	var sb strings.Builder
+	sb.WriteString("Acciones disponibles:\n")
+	for _, m := range config.Config.Manifests {
+		sb.WriteString("- ")
+		sb.WriteString(m.Name)
+		if m.Description != "" {
+			sb.WriteString(": ")
+			sb.WriteString(m.Description)
+		}
+		if len(m.Examples) > 0 {
+			sb.WriteString(" (ejemplos: \"")
+			sb.WriteString(strings.Join(m.Examples, "\", \""))
+			sb.WriteString("\")")
+		}
+		sb.WriteString("\n")
+		for _, p := range m.Parameters {
+			sb.WriteString("  - parámetro ")
+			sb.WriteString(p.Name)
+			if p.Required {
+				sb.WriteString(" (obligatorio)")
+			}
+			if p.Description != "" {
+				sb.WriteString(": ")
+				sb.WriteString(p.Description)
+			}
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
 
-	chatRequest := LMChatRequest{
-		Model:       os.Getenv("RESPONSE_MODEL_NAME"),
-		Messages:    messages,
-		Temperature: nil,
-		MaxTokens:   nil,
-		TopK:        nil,
-		TopP:        nil,
-		MinP:        nil,
+// Process realiza la clasificación usando el modelo clasificador, salvo que
+// el router de embeddings ya pueda elegir la acción. La llamada se cancela
+// si ctx se cancela.
+func Process(ctx context.Context, prompt string) (*Classification, error) {
+	if c := route(ctx, prompt); c != nil {
+		return c, nil
 	}
-
-	body, err := json.Marshal(chatRequest)
-	if err != nil {
-		return "", err
+	messages := []llm.Message{
+		{
+			Role:    "system",
+			Content: classifierSystemPrompt(),
+		},
 	}
+	messages = append(messages, fewShotMessages(prompt)...)
+	messages = append(messages, llm.Message{
+		Role:    "user",
+		Content: prompt,
+	})
 
-	fmt.Printf("Payload enviado: %s\n", string(body))
-
-	resp, err := http.Post(os.Getenv("RESPONSE_LM_API_URL"), "application/json", bytes.NewBuffer(body))
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
+	return classify(ctx, messages)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		responseBody, _ := ioutil.ReadAll(resp.Body)
-		fmt.Printf("Error en la respuesta del servidor: %s\n", string(responseBody))
-		return "", errors.New("error en la llamada al modelo LLM")
-	}
+// ProcessWithContext realiza la clasificación usando el contexto del modelo.
+// El prompt queda en el historial; el prompt de sistema se envía en cada
+// llamada sin guardarse, así aparece una sola vez.
+func ProcessWithContext(ctx context.Context, mctx mcp.ModelContext, prompt string) (*Classification, error) {
+	logger.Info("=== Iniciando procesamiento con contexto ===")
+	logger.Debug("Prompt recibido: %s", prompt)
+	logger.JSON("Contexto actual", mctx.GetMessages())
 
-	responseBody, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
+	systemContent := classifierSystemPrompt()
+	logger.Debug("System prompt: %s", systemContent)
+	mctx.AddMessage(mcp.RoleUser, prompt)
+	if c := route(ctx, prompt); c != nil {
+		return c, nil
 	}
 
-	return string(responseBody), nil
+	before := append([]llm.Message{
+		{
+			Role:    mcp.RoleSystem,
+			Content: systemContent,
+		},
+	}, fewShotMessages(prompt)...)
+	messages := contextWindow(ctx, mctx, config.RoleClassifier, before, nil)
+	return classify(ctx, messages)
 }
 
-// makeRequest realiza la llamada HTTP al modelo LLM.
-func makeRequest(apiURL string, payload RequestPayload) (string, error) {
-	messages := []ChatMessage{
+// Respond realiza una respuesta usando el modelo de respuestas, sin
+// historial. Devuelve el texto de la respuesta y los tokens consumidos.
+func Respond(ctx context.Context, prompt string) (string, Usage, error) {
+	return makeRequest(ctx, config.RoleResponder, []llm.Message{
 		{
-			Role:    "user",
-			Content: payload.Prompt,
+			Role:    mcp.RoleUser,
+			Content: prompt,
 		},
-	}
-
-	chatRequest := LMChatRequest{
-		Model:       payload.Model,
-		Messages:    messages,
-		Temperature: &payload.Temperature,
-		MaxTokens:   &payload.MaxTokens,
-		TopK:        &payload.TopK,
-		TopP:        &payload.TopP,
-		MinP:        &payload.MinP,
-	}
-
-	body, err := json.Marshal(chatRequest)
-	if err != nil {
-		return "", err
-	}
+	})
+}
 
-	fmt.Printf("Payload enviado: %s\n", string(body))
+// RespondWithContext realiza una respuesta usando el contexto del modelo. El
+// prompt y la respuesta del asistente quedan agregados al contexto.
+func RespondWithContext(ctx context.Context, mctx mcp.ModelContext, prompt string) (string, Usage, error) {
+	mctx.AddMessage(mcp.RoleUser, prompt)
 
-	resp, err := http.Post(apiURL, "application/json", bytes.NewBuffer(body))
+	messages := contextWindow(ctx, mctx, config.RoleResponder, nil, nil)
+	reply, usage, err := makeRequest(ctx, config.RoleResponder, messages)
 	if err != nil {
-		return "", err
+		return "", usage, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		responseBody, _ := ioutil.ReadAll(resp.Body)
-		fmt.Printf("Error en la respuesta del servidor: %s\n", string(responseBody))
-		return "", errors.New("error en la llamada al modelo LLM")
-	}
-
-	responseBody, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
-	}
-
-	return string(responseBody), nil
-}
-
-// parseFloat convierte una cadena a float32.
-func parseFloat(value string) float32 {
-	if f, err := strconv.ParseFloat(value, 32); err == nil {
-		return float32(f)
-	}
-	return 0.0
+	mctx.AddMessage(mcp.RoleAssistant, reply)
+	return reply, usage, nil
 }
 
-// parseInt convierte una cadena a int.
-func parseInt(value string) int {
-	if i, err := strconv.Atoi(value); err == nil {
-		return i
+// makeRequest envía los mensajes al modelo del rol y devuelve el contenido
+// de la respuesta.
+func makeRequest(ctx context.Context, role string, messages []llm.Message) (string, Usage, error) {
+	var resp *llm.ChatResponse
+	err := withProfile(ctx, role, func(ctx context.Context, backend llm.Backend, profile config.ModelProfile) error {
+		var err error
+		resp, err = backend.Chat(ctx, chatRequest(profile, messages))
+		return err
+	})
+	if err != nil {
+		return "", Usage{}, err
 	}
-	return 0
+	logger.Debug("Tokens usados: %d de prompt, %d de respuesta", resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
+	return resp.Content, resp.Usage, nil
 }
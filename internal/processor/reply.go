@@ -0,0 +1,102 @@
+package processor
+
+import (
+	"context"
+
+	"github.com/ivanneira/Lapislazuli/config"
+	"github.com/ivanneira/Lapislazuli/internal/llm"
+	"github.com/ivanneira/Lapislazuli/internal/logger"
+	"github.com/ivanneira/Lapislazuli/internal/mcp"
+)
+
+// historyMessages convierte el historial del contexto en mensajes de chat.
+// Los resultados de acciones, las llamadas a herramientas y sus resultados y
+// el resumen se envían como mensajes de sistema, que todos los servidores
+// compatibles con OpenAI aceptan. Los mensajes de sistema guardados en
+// sesiones viejas se omiten: cada llamada agrega su propio prompt de sistema.
+func historyMessages(history []mcp.Message) []llm.Message {
+	messages := make([]llm.Message, 0, len(history))
+	for _, msg := range history {
+		role := msg.Role
+		content := msg.Content
+		switch role {
+		case mcp.RoleSystem:
+			continue
+		case mcp.RoleAction:
+			role = mcp.RoleSystem
+		case mcp.RoleSummary:
+			role = mcp.RoleSystem
+			content = "Resumen de la conversación anterior: " + content
+		case mcp.RoleToolCall:
+			role = mcp.RoleSystem
+			content = "Llamada a herramienta: " + content
+		case mcp.RoleTool:
+			role = mcp.RoleSystem
+			content = "Resultado de la herramienta: " + content
+		}
+		messages = append(messages, llm.Message{
+			Role:    role,
+			Content: content,
+		})
+	}
+	return messages
+}
+
+// Reply genera con el modelo de respuestas una respuesta en lenguaje natural
+// para el último turno del contexto, siguiendo la instrucción dada. La
+// respuesta se agrega al contexto como mensaje del asistente. La llamada se
+// cancela si ctx se cancela.
+func Reply(ctx context.Context, mctx mcp.ModelContext, instruction string) (string, Usage, error) {
+	logger.Info("=== Generando respuesta ===")
+
+	messages := contextWindow(ctx, mctx, config.RoleResponder, nil, []llm.Message{
+		{
+			Role:    mcp.RoleSystem,
+			Content: instruction,
+		},
+	})
+
+	reply, usage, err := makeRequest(ctx, config.RoleResponder, messages)
+	if err != nil {
+		return "", usage, err
+	}
+	mctx.AddMessage(mcp.RoleAssistant, reply)
+	return reply, usage, nil
+}
+
+// StreamReply es como Reply pero pide la respuesta en streaming y llama a
+// onToken con cada fragmento de texto a medida que llega.
+func StreamReply(ctx context.Context, mctx mcp.ModelContext, instruction string, onToken func(string)) (string, Usage, error) {
+	logger.Info("=== Generando respuesta en streaming ===")
+
+	messages := contextWindow(ctx, mctx, config.RoleResponder, nil, []llm.Message{
+		{
+			Role:    mcp.RoleSystem,
+			Content: instruction,
+		},
+	})
+
+	// Si ya se entregaron fragmentos no se puede pasar al perfil de respaldo
+	emitted := false
+	var resp *llm.ChatResponse
+	err := withProfile(ctx, config.RoleResponder, func(lctx context.Context, backend llm.Backend, profile config.ModelProfile) error {
+		var err error
+		resp, err = backend.Stream(lctx, chatRequest(profile, messages), func(token string) {
+			emitted = true
+			if onToken != nil {
+				onToken(token)
+			}
+		})
+		if err != nil && emitted {
+			return llm.Permanent(err)
+		}
+		return err
+	})
+	if err != nil {
+		return "", Usage{}, err
+	}
+
+	reply := resp.Content
+	mctx.AddMessage(mcp.RoleAssistant, reply)
+	return reply, resp.Usage, nil
+}
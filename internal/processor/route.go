@@ -0,0 +1,86 @@
+package processor
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ivanneira/Lapislazuli/config"
+	"github.com/ivanneira/Lapislazuli/internal/actions"
+	"github.com/ivanneira/Lapislazuli/internal/llm"
+	"github.com/ivanneira/Lapislazuli/internal/logger"
+	"github.com/ivanneira/Lapislazuli/internal/router"
+)
+
+var (
+	routerOnce sync.Once
+	intents    *router.Router
+)
+
+// intentRouter devuelve el router de embeddings armado con los ejemplos de
+// la configuración, o nil si ROUTER está apagado o no hay ejemplos.
+func intentRouter() *router.Router {
+	routerOnce.Do(func() {
+		if !config.Config.Router || config.Config.Examples.Len() == 0 {
+			return
+		}
+		cache, err := router.OpenCache(config.Config.RouterCache)
+		if err != nil {
+			logger.Warn("No se pudo leer la caché de embeddings %s: %v", config.Config.RouterCache, err)
+		}
+		profile := config.Model(config.RoleEmbedder)
+		intents = router.New(profile.Model, embed, cache, config.Config.Examples.All())
+	})
+	return intents
+}
+
+// embed calcula los embeddings de texts con el modelo del rol embedder.
+func embed(ctx context.Context, texts []string) ([][]float32, error) {
+	var vectors [][]float32
+	err := withProfile(ctx, config.RoleEmbedder, func(ctx context.Context, backend llm.Backend, profile config.ModelProfile) error {
+		var err error
+		vectors, err = backend.Embed(ctx, profile.Model, texts)
+		return err
+	})
+	return vectors, err
+}
+
+// route intenta elegir la acción del prompt por similitud de embeddings con
+// los ejemplos. Solo decide si la similitud supera ROUTER_THRESHOLD y la
+// acción no tiene parámetros que extraer; si no, devuelve nil y la
+// clasificación queda a cargo del modelo. Los errores del router no cortan
+// la petición: se registran y se sigue con el clasificador.
+func route(ctx context.Context, prompt string) *Classification {
+	r := intentRouter()
+	if r == nil {
+		return nil
+	}
+	scores, err := r.Rank(ctx, prompt)
+	if err != nil {
+		logger.Warn("El router de embeddings falló, se usa el clasificador: %v", err)
+		return nil
+	}
+	if len(scores) == 0 || scores[0].Similarity < config.Config.RouterThreshold {
+		return nil
+	}
+
+	best := scores[0]
+	if best.Action != actions.None {
+		m, ok := actions.Find(config.Config.Manifests, best.Action)
+		if !ok || len(m.Parameters) > 0 {
+			logger.Debug("El router eligió %s, pero sus parámetros los extrae el clasificador", best.Action)
+			return nil
+		}
+	}
+
+	candidates := make([]Candidate, len(scores))
+	for i, s := range scores {
+		candidates[i] = Candidate{Action: s.Action, Confidence: clamp(s.Similarity)}
+	}
+	logger.Info("Acción elegida por el router: %s (similitud %.2f)", best.Action, best.Similarity)
+	return &Classification{
+		Action:     best.Action,
+		Params:     map[string]interface{}{},
+		Confidence: clamp(best.Similarity),
+		Candidates: candidates,
+	}
+}
@@ -0,0 +1,149 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ivanneira/Lapislazuli/config"
+	"github.com/ivanneira/Lapislazuli/internal/llm"
+	"github.com/ivanneira/Lapislazuli/internal/logger"
+	"github.com/ivanneira/Lapislazuli/internal/mcp"
+)
+
+const (
+	// messageOverhead son los tokens que ocupan el rol y los separadores de
+	// cada mensaje en la plantilla de chat.
+	messageOverhead = 4
+	// replyReserve son los tokens reservados para la respuesta cuando el
+	// perfil no define MaxTokens.
+	replyReserve = 512
+)
+
+// summarizeInstruction es la instrucción de sistema para resumir los mensajes
+// que no entran en la ventana de contexto.
+const summarizeInstruction = "Resumí la siguiente conversación entre un usuario y un asistente que ejecuta acciones " +
+	"en pocas oraciones. Conservá los nombres, datos y pedidos pendientes que puedan hacer falta para seguirla."
+
+// estimateTokens estima los tokens de un mensaje: unos 4 caracteres por
+// token más lo que ocupa el mensaje en la plantilla de chat.
+func estimateTokens(content string) int {
+	return utf8.RuneCountInString(content)/4 + messageOverhead
+}
+
+// historyBudget devuelve los tokens disponibles para el historial en la
+// ventana del perfil, descontando los mensajes fijos y la respuesta.
+func historyBudget(profile config.ModelProfile, fixed []llm.Message) int {
+	budget := profile.ContextTokens
+	if profile.MaxTokens > 0 {
+		budget -= profile.MaxTokens
+	} else {
+		budget -= replyReserve
+	}
+	for _, msg := range fixed {
+		budget -= estimateTokens(msg.Content)
+	}
+	return budget
+}
+
+// windowStart devuelve el índice del mensaje más viejo de history que entra
+// en budget, empezando siempre en un mensaje del usuario para no partir un
+// turno. El último mensaje se conserva aunque no entre.
+func windowStart(history []mcp.Message, budget int) int {
+	used := 0
+	start := len(history)
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role == mcp.RoleSystem {
+			continue
+		}
+		used += estimateTokens(history[i].Content)
+		if used > budget && start < len(history) {
+			break
+		}
+		start = i
+	}
+	if start == 0 {
+		return 0
+	}
+	for i := start; i < len(history); i++ {
+		if history[i].Role == mcp.RoleUser {
+			return i
+		}
+	}
+	return start
+}
+
+// contextWindow arma los mensajes para el modelo del rol: before, el
+// historial de mctx que entra en la ventana de contexto y after. Los mensajes
+// viejos que no entran se descartan o, con CONTEXT_STRATEGY=summarize, se
+// reemplazan en mctx por un resumen.
+func contextWindow(ctx context.Context, mctx mcp.ModelContext, role string, before, after []llm.Message) []llm.Message {
+	profile := config.Model(role)
+	budget := historyBudget(profile, append(append([]llm.Message{}, before...), after...))
+	history := mctx.GetMessages()
+	start := windowStart(history, budget)
+
+	if start > 0 && config.Config.ContextStrategy == config.ContextSummarize {
+		summary, err := summarize(ctx, history[:start])
+		if err != nil {
+			logger.Warn("No se pudo resumir el historial, se descartan los mensajes viejos: %v", err)
+		} else {
+			mctx.Compact(start, summary)
+			history = mctx.GetMessages()
+			start = windowStart(history, budget)
+		}
+	}
+	if start > 0 {
+		logger.Info("Se omiten %d mensajes viejos que no entran en la ventana de %d tokens del perfil %s",
+			start, profile.ContextTokens, profile.Name)
+	}
+
+	messages := make([]llm.Message, 0, len(before)+len(history)-start+len(after))
+	messages = append(messages, before...)
+	messages = append(messages, historyMessages(history[start:])...)
+	return append(messages, after...)
+}
+
+// summarize resume los mensajes con el modelo del rol summarizer.
+func summarize(ctx context.Context, history []mcp.Message) (string, error) {
+	logger.Info("=== Resumiendo %d mensajes del historial ===", len(history))
+
+	var transcript strings.Builder
+	for _, msg := range history {
+		switch msg.Role {
+		case mcp.RoleSystem:
+			continue
+		case mcp.RoleSummary:
+			transcript.WriteString("Resumen anterior: ")
+		default:
+			transcript.WriteString(msg.Role + ": ")
+		}
+		transcript.WriteString(msg.Content)
+		transcript.WriteString("\n")
+	}
+
+	// Si la transcripción no entra en la ventana del resumidor, se conserva
+	// el final
+	text := transcript.String()
+	profile := config.Model(config.RoleSummarizer)
+	instruction := llm.Message{Role: mcp.RoleSystem, Content: summarizeInstruction}
+	if limit := historyBudget(profile, []llm.Message{instruction}) * 4; limit > 0 && len(text) > limit {
+		text = text[len(text)-limit:]
+		for !utf8.ValidString(text) {
+			text = text[1:]
+		}
+	}
+
+	summary, _, err := makeRequest(ctx, config.RoleSummarizer, []llm.Message{
+		instruction,
+		{Role: mcp.RoleUser, Content: text},
+	})
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(summary) == "" {
+		return "", fmt.Errorf("el modelo devolvió un resumen vacío")
+	}
+	return summary, nil
+}
@@ -0,0 +1,88 @@
+package router
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Cache guarda embeddings por modelo y texto en un archivo JSON, para no
+// volver a calcular los de los ejemplos en cada arranque.
+type Cache struct {
+	mu      sync.Mutex
+	path    string
+	vectors map[string][]float32
+	dirty   bool
+}
+
+// OpenCache carga la caché de path. Si el archivo no existe empieza vacía;
+// con path vacío la caché solo vive en memoria.
+func OpenCache(path string) (*Cache, error) {
+	c := &Cache{path: path, vectors: map[string][]float32{}}
+	if path == "" {
+		return c, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(data, &c.vectors); err != nil {
+		return &Cache{path: path, vectors: map[string][]float32{}}, err
+	}
+	return c, nil
+}
+
+func cacheKey(model, text string) string {
+	sum := sha256.Sum256([]byte(model + "\x00" + text))
+	return hex.EncodeToString(sum[:])
+}
+
+// Get devuelve el embedding de text con model, si está en la caché.
+func (c *Cache) Get(model, text string) ([]float32, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.vectors[cacheKey(model, text)]
+	return v, ok
+}
+
+// Put agrega el embedding de text con model.
+func (c *Cache) Put(model, text string, vector []float32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.vectors[cacheKey(model, text)] = vector
+	c.dirty = true
+}
+
+// Save escribe la caché en disco si cambió.
+func (c *Cache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.path == "" || !c.dirty {
+		return nil
+	}
+	data, err := json.Marshal(c.vectors)
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(c.path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
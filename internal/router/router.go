@@ -0,0 +1,146 @@
+// Package router elige la acción de un prompt comparando su embedding con
+// los de las frases de ejemplo de cada acción, sin pasar por el clasificador.
+package router
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/ivanneira/Lapislazuli/internal/examples"
+	"github.com/ivanneira/Lapislazuli/internal/logger"
+)
+
+// EmbedFunc calcula un embedding por cada texto.
+type EmbedFunc func(ctx context.Context, texts []string) ([][]float32, error)
+
+// Score es la mayor similitud coseno entre el prompt y los ejemplos de una
+// acción.
+type Score struct {
+	Action     string
+	Similarity float64
+}
+
+// Router compara prompts con los ejemplos. Los embeddings de los ejemplos se
+// calculan la primera vez que se usa y se guardan en la caché.
+type Router struct {
+	model    string
+	embed    EmbedFunc
+	cache    *Cache
+	examples []examples.Example
+
+	mu      sync.Mutex
+	vectors [][]float32
+}
+
+// New crea un Router para los ejemplos, con los embeddings de model.
+func New(model string, embed EmbedFunc, cache *Cache, list []examples.Example) *Router {
+	return &Router{model: model, embed: embed, cache: cache, examples: list}
+}
+
+// Rank devuelve la similitud del prompt con cada acción, de mayor a menor.
+func (r *Router) Rank(ctx context.Context, prompt string) ([]Score, error) {
+	vectors, err := r.exampleVectors(ctx)
+	if err != nil {
+		return nil, err
+	}
+	query, err := r.vectorsFor(ctx, []string{prompt}, false)
+	if err != nil {
+		return nil, err
+	}
+
+	best := map[string]float64{}
+	for i, v := range vectors {
+		sim := cosine(query[0], v)
+		action := r.examples[i].Action
+		if current, ok := best[action]; !ok || sim > current {
+			best[action] = sim
+		}
+	}
+
+	scores := make([]Score, 0, len(best))
+	for action, sim := range best {
+		scores = append(scores, Score{Action: action, Similarity: sim})
+	}
+	sort.Slice(scores, func(i, j int) bool {
+		if scores[i].Similarity != scores[j].Similarity {
+			return scores[i].Similarity > scores[j].Similarity
+		}
+		return scores[i].Action < scores[j].Action
+	})
+	return scores, nil
+}
+
+// exampleVectors calcula, una sola vez, los embeddings de los ejemplos.
+func (r *Router) exampleVectors(ctx context.Context) ([][]float32, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.vectors != nil {
+		return r.vectors, nil
+	}
+
+	texts := make([]string, len(r.examples))
+	for i, e := range r.examples {
+		texts[i] = e.Text
+	}
+	vectors, err := r.vectorsFor(ctx, texts, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.cache.Save(); err != nil {
+		logger.Warn("No se pudo guardar la caché de embeddings: %v", err)
+	}
+	r.vectors = vectors
+	return vectors, nil
+}
+
+// vectorsFor devuelve los embeddings de texts, calculando solo los que no
+// están en la caché. Con store los nuevos se agregan a la caché.
+func (r *Router) vectorsFor(ctx context.Context, texts []string, store bool) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	var missing []string
+	var positions []int
+	for i, text := range texts {
+		if v, ok := r.cache.Get(r.model, text); ok {
+			vectors[i] = v
+			continue
+		}
+		missing = append(missing, text)
+		positions = append(positions, i)
+	}
+	if len(missing) == 0 {
+		return vectors, nil
+	}
+
+	logger.Info("Calculando %d embeddings con %s", len(missing), r.model)
+	computed, err := r.embed(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	for j, v := range computed {
+		vectors[positions[j]] = v
+		if store {
+			r.cache.Put(r.model, missing[j], v)
+		}
+	}
+	return vectors, nil
+}
+
+// cosine devuelve la similitud coseno entre a y b. Si no tienen la misma
+// dimensión devuelve 0.
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
@@ -0,0 +1,165 @@
+// Package actionsdk implementa el protocolo de entrada/salida entre Lapislazuli
+// y los ejecutables de acciones.
+//
+// El coordinador escribe un Request en JSON por la entrada estándar del
+// ejecutable y espera un Response en JSON por la salida estándar. Todo lo que
+// la acción escriba en stderr se muestra en el log del servidor.
+//
+// Una acción mínima:
+//
+//	func main() {
+//		actionsdk.Run(func(req *actionsdk.Request) (*actionsdk.Response, error) {
+//			nombre, _ := req.String("nombre")
+//			return actionsdk.OK("Hola " + nombre), nil
+//		})
+//	}
+package actionsdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// ProtocolVersion es la versión del protocolo implementada por este paquete.
+const ProtocolVersion = "1"
+
+// Estados posibles de una respuesta.
+const (
+	StatusOK         = "ok"
+	StatusError      = "error"
+	StatusNeedsInput = "needs_input"
+)
+
+// Request es el sobre que recibe la acción por stdin.
+type Request struct {
+	Version   string                 `json:"version"`
+	Action    string                 `json:"action"`
+	Prompt    string                 `json:"prompt"`
+	Params    map[string]interface{} `json:"params"`
+	SessionID string                 `json:"session_id,omitempty"`
+	Locale    string                 `json:"locale,omitempty"`
+}
+
+// Response es lo que la acción devuelve por stdout. Solo Message y Status son
+// obligatorios, por lo que las acciones anteriores al protocolo siguen siendo
+// compatibles.
+type Response struct {
+	Version   string                 `json:"version,omitempty"`
+	Message   string                 `json:"message"`
+	Status    string                 `json:"status"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+	FollowUp  []string               `json:"follow_up,omitempty"`
+	ErrorCode string                 `json:"error_code,omitempty"`
+}
+
+// OK crea una respuesta exitosa.
+func OK(message string) *Response {
+	return &Response{Version: ProtocolVersion, Message: message, Status: StatusOK}
+}
+
+// Errorf crea una respuesta de error con un código legible por máquinas.
+func Errorf(code, format string, v ...interface{}) *Response {
+	return &Response{
+		Version:   ProtocolVersion,
+		Message:   fmt.Sprintf(format, v...),
+		Status:    StatusError,
+		ErrorCode: code,
+	}
+}
+
+// Ask crea una respuesta que pide más información al usuario.
+func Ask(message string, questions ...string) *Response {
+	return &Response{
+		Version:  ProtocolVersion,
+		Message:  message,
+		Status:   StatusNeedsInput,
+		FollowUp: questions,
+	}
+}
+
+// String devuelve un parámetro de texto.
+func (r *Request) String(name string) (string, bool) {
+	v, ok := r.Params[name].(string)
+	return v, ok
+}
+
+// Float devuelve un parámetro numérico.
+func (r *Request) Float(name string) (float64, bool) {
+	v, ok := r.Params[name].(float64)
+	return v, ok
+}
+
+// Int devuelve un parámetro entero.
+func (r *Request) Int(name string) (int, bool) {
+	v, ok := r.Params[name].(float64)
+	if !ok || v != math.Trunc(v) {
+		return 0, false
+	}
+	return int(v), true
+}
+
+// Bool devuelve un parámetro booleano.
+func (r *Request) Bool(name string) (bool, bool) {
+	v, ok := r.Params[name].(bool)
+	return v, ok
+}
+
+// ReadRequest decodifica un Request. Una entrada vacía se interpreta como un
+// Request sin parámetros, para poder probar la acción a mano.
+func ReadRequest(r io.Reader) (*Request, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	req := &Request{Params: map[string]interface{}{}}
+	if len(data) == 0 {
+		return req, nil
+	}
+	if err := json.Unmarshal(data, req); err != nil {
+		return nil, fmt.Errorf("request inválido: %v", err)
+	}
+	if req.Params == nil {
+		req.Params = map[string]interface{}{}
+	}
+	return req, nil
+}
+
+// WriteResponse codifica un Response completando la versión del protocolo.
+func WriteResponse(w io.Writer, resp *Response) error {
+	if resp.Version == "" {
+		resp.Version = ProtocolVersion
+	}
+	return json.NewEncoder(w).Encode(resp)
+}
+
+// Handler procesa un Request. Un error devuelto se informa como respuesta con
+// código "internal".
+type Handler func(req *Request) (*Response, error)
+
+// Run lee el Request de stdin, ejecuta el handler y escribe la respuesta en
+// stdout. Termina el proceso con código 1 si no pudo responder.
+func Run(handler Handler) {
+	if err := Serve(os.Stdin, os.Stdout, handler); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// Serve es como Run pero con entrada y salida explícitas, útil para pruebas.
+func Serve(in io.Reader, out io.Writer, handler Handler) error {
+	req, err := ReadRequest(in)
+	if err != nil {
+		return WriteResponse(out, Errorf("bad_request", "%v", err))
+	}
+
+	resp, err := handler(req)
+	if err != nil {
+		resp = Errorf("internal", "%v", err)
+	} else if resp == nil {
+		resp = OK("")
+	}
+	return WriteResponse(out, resp)
+}